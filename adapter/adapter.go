@@ -2,6 +2,7 @@ package adapter
 
 import (
 	"context"
+	"strings"
 	"time"
 )
 
@@ -23,3 +24,77 @@ type Adapter interface {
 type Lock interface {
 	Release(ctx context.Context) error
 }
+
+// Optional capability of a lock to have its lease extended while held.
+// Extendable locks are extended periodically while the action is being performed, allowing a short TTL.
+type ExtendableLock interface {
+	Lock
+
+	// Extend the lease of the lock by its TTL.
+	Extend(ctx context.Context) error
+
+	// The TTL of the lease of the lock.
+	TTL() time.Duration
+}
+
+// Optional capability of a lock to provide a fencing token, which is greater for every lock obtained later.
+type FencedLock interface {
+	Lock
+
+	Token() int64
+}
+
+// Optional capability of an adapter to reject writes from lock holders which have been superseded.
+type FencingAdapter interface {
+	// Set the data only if the token is not older than the token of the last fenced write of the key, atomically.
+	// Returns whether the data is set.
+	SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error)
+}
+
+// Optional capability of an adapter to operate on multiple keys in a single call.
+type BatchAdapter interface {
+	// Get data of the given keys, in the same order. Data of keys which are not found is nil.
+	GetMany(ctx context.Context, keys []string) ([][]byte, error)
+	SetMany(ctx context.Context, items []Item) error
+}
+
+type Item struct {
+	Key  string
+	TTL  time.Duration
+	Data []byte
+}
+
+// Optional capability of an adapter to index keys by tags.
+type TagAdapter interface {
+	// Associate the key with the given tags. The association must last at least for the given TTL.
+	Tag(ctx context.Context, key string, ttl time.Duration, tags []string) error
+
+	// Delete every key associated with the tag, along with the tag itself.
+	DeleteTag(ctx context.Context, tag string) error
+}
+
+// Optional capability of an adapter to atomically increment counters.
+//
+// Counters must be readable through Adapter.Get as a decimal string, with missing counters being zero.
+type CounterAdapter interface {
+	// Increment the counter and return the new value. The TTL is only applied if positive.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// Prefixes of the keys of counters maintained by actors.
+const (
+	GenerationKeyPrefix = "gen###"
+	EpochKeyPrefix      = "epoch###"
+)
+
+// Whether the key holds a counter maintained by actors.
+//
+// Such counters are incremented by other processes, so adapters keeping data locally must not serve them from a local copy.
+func IsCounterKey(key string) bool {
+	return strings.HasPrefix(key, GenerationKeyPrefix) || strings.HasPrefix(key, EpochKeyPrefix)
+}
+
+// Optional capability of an adapter to delete keys by prefix.
+type PrefixAdapter interface {
+	DeletePrefix(ctx context.Context, prefix string) error
+}
@@ -0,0 +1,36 @@
+package adapter
+
+import "context"
+
+type InvalidationKind int
+
+const (
+	InvalidateKey InvalidationKind = iota
+	InvalidatePrefix
+	InvalidateTag
+)
+
+// Invalidation of data kept locally by other processes.
+type Invalidation struct {
+	Kind InvalidationKind
+
+	// The key, prefix, or tag to invalidate, depending on the kind.
+	Value string
+}
+
+// Distributes invalidations across processes keeping data locally.
+type InvalidationBus interface {
+	Publish(ctx context.Context, invalidation Invalidation) error
+
+	// Subscribe to invalidations published by other processes. Returns once subscribed, or with the error of subscribing.
+	// Invalidations are then received in background until the context is done, reconnecting as needed.
+	Subscribe(ctx context.Context, handler InvalidationHandler) error
+}
+
+type InvalidationHandler interface {
+	Invalidate(ctx context.Context, invalidation Invalidation)
+
+	// Called whenever invalidations might have been missed, such as while disconnected.
+	// All data kept locally should be discarded.
+	Flush(ctx context.Context)
+}
@@ -0,0 +1,311 @@
+package disk
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/mutex"
+	"github.com/ezraisw/wracha/adapter/util/mutex/sync"
+	bolt "go.etcd.io/bbolt"
+)
+
+const DefaultSweepInterval = 1 * time.Minute
+
+// Maximum duration to wait for the file lock of the database, held by another process.
+const openTimeout = 1 * time.Second
+
+var (
+	// Data of each key, prefixed with its expiry.
+	entriesBucket = []byte("entries")
+
+	// Keys ordered by their expiry, for the sweeper to find expired data without scanning every entry.
+	expiriesBucket = []byte("expiries")
+)
+
+type diskAdapter struct {
+	db     *bolt.DB
+	locker mutex.Locker
+
+	// Deprecated
+	multiMutex *mutex.MultiMutex
+}
+
+// Create an adapter storing data in a bbolt database at the given path, which is created if missing.
+//
+// Expired data is deleted once read, and swept in background until the context is done, after which the database is closed.
+// The database is locked by bbolt for as long as it is open, so it cannot be used by multiple processes at once.
+func NewAdapter(ctx context.Context, path string) (adapter.Adapter, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := newAdapter(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	go func() {
+		a.sweep(ctx, DefaultSweepInterval)
+		db.Close()
+	}()
+
+	return a, nil
+}
+
+// Create an adapter storing data in the given database, sweeping expired data in the given interval until the context is done.
+//
+// The database is left open for the caller to close.
+func NewAdapterWithDB(ctx context.Context, db *bolt.DB, sweepInterval time.Duration) (adapter.Adapter, error) {
+	a, err := newAdapter(db)
+	if err != nil {
+		return nil, err
+	}
+
+	go a.sweep(ctx, sweepInterval)
+
+	return a, nil
+}
+
+func newAdapter(db *bolt.DB) (*diskAdapter, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(entriesBucket); err != nil {
+			return err
+		}
+
+		_, err := tx.CreateBucketIfNotExists(expiriesBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &diskAdapter{
+		db:     db,
+		locker: sync.NewLocker(),
+
+		multiMutex: mutex.NewMultiMutex(sync.NewMutexFactory()),
+	}, nil
+}
+
+func (a diskAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	datas, err := a.GetMany(ctx, []string{key})
+	if err != nil {
+		return false, err
+	}
+
+	return datas[0] != nil, nil
+}
+
+func (a diskAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	datas, err := a.GetMany(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+
+	if datas[0] == nil {
+		return nil, adapter.ErrNotFound
+	}
+
+	return datas[0], nil
+}
+
+func (a diskAdapter) Set(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, []byte(key), getExpiresAt(ttl), data)
+	})
+}
+
+func (a diskAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	now := time.Now().UnixNano()
+
+	datas := make([][]byte, len(keys))
+	expiredKeys := make([][]byte, 0)
+
+	err := a.db.View(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		for i, key := range keys {
+			entry := entries.Get([]byte(key))
+			if entry == nil {
+				continue
+			}
+
+			expiresAt, data := decodeEntry(entry)
+			if isExpired(expiresAt, now) {
+				expiredKeys = append(expiredKeys, []byte(key))
+				continue
+			}
+
+			// Data is only valid within the transaction.
+			datas[i] = bytes.Clone(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(expiredKeys) == 0 {
+		return datas, nil
+	}
+
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range expiredKeys {
+			// Might have been written again meanwhile.
+			if err := removeExpired(tx, key, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return datas, nil
+}
+
+func (a diskAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		for _, item := range items {
+			if err := put(tx, []byte(item.Key), getExpiresAt(item.TTL), item.Data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a diskAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	var count int64
+
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		expiresAt := getExpiresAt(ttl)
+
+		if entry := tx.Bucket(entriesBucket).Get([]byte(key)); entry != nil {
+			currentExpiresAt, data := decodeEntry(entry)
+			if !isExpired(currentExpiresAt, time.Now().UnixNano()) {
+				// Ignore parsing errors.
+				count, _ = strconv.ParseInt(string(data), 10, 64)
+
+				// Retain the remaining TTL of the counter unless specified.
+				if ttl <= 0 {
+					expiresAt = currentExpiresAt
+				}
+			}
+		}
+
+		count++
+		return put(tx, []byte(key), expiresAt, []byte(strconv.FormatInt(count, 10)))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (a diskAdapter) Delete(ctx context.Context, key string) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		return remove(tx, []byte(key))
+	})
+}
+
+func (a diskAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		p := []byte(prefix)
+
+		// Collected first, as deleting while iterating skips keys.
+		keys := make([][]byte, 0)
+		c := tx.Bucket(entriesBucket).Cursor()
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+
+		for _, key := range keys {
+			if err := remove(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deprecated
+func (a diskAdapter) Lock(ctx context.Context, key string) error {
+	return a.multiMutex.Lock(ctx, key)
+}
+
+// Deprecated
+func (a diskAdapter) Unlock(ctx context.Context, key string) error {
+	return a.multiMutex.Unlock(ctx, key)
+}
+
+// Locks are held in-process, as the database cannot be opened by other processes meanwhile.
+func (a diskAdapter) ObtainLock(ctx context.Context, key string) (adapter.Lock, error) {
+	return a.locker.Obtain(ctx, key)
+}
+
+func put(tx *bolt.Tx, key []byte, expiresAt int64, data []byte) error {
+	if err := remove(tx, key); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(entriesBucket).Put(key, encodeEntry(expiresAt, data)); err != nil {
+		return err
+	}
+
+	if expiresAt == 0 {
+		return nil
+	}
+
+	return tx.Bucket(expiriesBucket).Put(encodeExpiryKey(expiresAt, key), nil)
+}
+
+func remove(tx *bolt.Tx, key []byte) error {
+	entries := tx.Bucket(entriesBucket)
+
+	entry := entries.Get(key)
+	if entry == nil {
+		return nil
+	}
+
+	expiresAt, _ := decodeEntry(entry)
+	if expiresAt != 0 {
+		if err := tx.Bucket(expiriesBucket).Delete(encodeExpiryKey(expiresAt, key)); err != nil {
+			return err
+		}
+	}
+
+	return entries.Delete(key)
+}
+
+func removeExpired(tx *bolt.Tx, key []byte, now int64) error {
+	entry := tx.Bucket(entriesBucket).Get(key)
+	if entry == nil {
+		return nil
+	}
+
+	if expiresAt, _ := decodeEntry(entry); !isExpired(expiresAt, now) {
+		return nil
+	}
+
+	return remove(tx, key)
+}
+
+// Zero means the data does not expire.
+func getExpiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(ttl).UnixNano()
+}
+
+func isExpired(expiresAt int64, now int64) bool {
+	return expiresAt != 0 && expiresAt <= now
+}
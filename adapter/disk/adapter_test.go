@@ -0,0 +1,166 @@
+package disk
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openDB(t *testing.T) *bolt.DB {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func countKeys(t *testing.T, db *bolt.DB, bucket []byte) int {
+	count := 0
+	err := db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(bucket).Stats().KeyN
+		return nil
+	})
+	assert.NoError(t, err)
+	return count
+}
+
+func TestGetSetDelete(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err := NewAdapterWithDB(ctx, openDB(t), DefaultSweepInterval)
+	assert.NoError(t, err)
+
+	_, err = a.Get(ctx, "key")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+
+	assert.NoError(t, a.Set(ctx, "key", time.Minute, []byte("value")))
+
+	data, err := a.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	exists, err := a.Exists(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, a.Delete(ctx, "key"))
+
+	exists, err = a.Exists(ctx, "key")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestLazyExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := openDB(t)
+	a, err := NewAdapterWithDB(ctx, db, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.Set(ctx, "key", 50*time.Millisecond, []byte("value")))
+	assert.NoError(t, a.Set(ctx, "persistent", 0, []byte("value")))
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = a.Get(ctx, "key")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+
+	// Deleted along with its index by the read, long before the sweep.
+	assert.Equal(t, 1, countKeys(t, db, entriesBucket))
+	assert.Equal(t, 0, countKeys(t, db, expiriesBucket))
+}
+
+func TestSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := openDB(t)
+	a, err := NewAdapterWithDB(ctx, db, 50*time.Millisecond)
+	assert.NoError(t, err)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, a.Set(ctx, key, 50*time.Millisecond, []byte("value")))
+	}
+	assert.NoError(t, a.Set(ctx, "d", time.Hour, []byte("value")))
+
+	assert.Eventually(t, func() bool {
+		return countKeys(t, db, entriesBucket) == 1 && countKeys(t, db, expiriesBucket) == 1
+	}, time.Second, 25*time.Millisecond)
+
+	data, err := a.Get(ctx, "d")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+}
+
+func TestOverwrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := openDB(t)
+	a, err := NewAdapterWithDB(ctx, db, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.Set(ctx, "key", time.Minute, []byte("first")))
+	assert.NoError(t, a.Set(ctx, "key", time.Hour, []byte("second")))
+
+	// The expiry of the first write is no longer indexed.
+	assert.Equal(t, 1, countKeys(t, db, expiriesBucket))
+
+	data, err := a.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+}
+
+func TestIncrAndDeletePrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err := NewAdapterWithDB(ctx, openDB(t), DefaultSweepInterval)
+	assert.NoError(t, err)
+
+	for i := int64(1); i <= 3; i++ {
+		count, err := a.(adapter.CounterAdapter).Incr(ctx, "prefix###counter", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, count)
+	}
+
+	assert.NoError(t, a.Set(ctx, "prefix###key", time.Minute, []byte("value")))
+	assert.NoError(t, a.Set(ctx, "other", time.Minute, []byte("value")))
+	assert.NoError(t, a.(adapter.PrefixAdapter).DeletePrefix(ctx, "prefix###"))
+
+	for key, expected := range map[string]bool{"prefix###counter": false, "prefix###key": false, "other": true} {
+		exists, err := a.Exists(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, exists, key)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a, err := NewAdapter(ctx, path)
+	assert.NoError(t, err)
+	assert.NoError(t, a.Set(ctx, "key", time.Hour, []byte("value")))
+
+	// The database is closed once the context is done, releasing its file lock.
+	cancel()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err = NewAdapter(ctx, path)
+	assert.NoError(t, err)
+
+	data, err := a.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+}
@@ -0,0 +1,31 @@
+package disk
+
+import "encoding/binary"
+
+// Entries are stored as the expiry in unix nanoseconds (big-endian), followed by the data.
+func encodeEntry(expiresAt int64, data []byte) []byte {
+	entry := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(entry, uint64(expiresAt))
+	copy(entry[8:], data)
+	return entry
+}
+
+func decodeEntry(entry []byte) (int64, []byte) {
+	if len(entry) < 8 {
+		return 0, entry
+	}
+
+	return int64(binary.BigEndian.Uint64(entry)), entry[8:]
+}
+
+// Keys of the expiry index are prefixed with the expiry in big-endian, such that they are ordered by expiry.
+func encodeExpiryKey(expiresAt int64, key []byte) []byte {
+	expiryKey := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(expiryKey, uint64(expiresAt))
+	copy(expiryKey[8:], key)
+	return expiryKey
+}
+
+func decodeExpiryKey(expiryKey []byte) (int64, []byte) {
+	return int64(binary.BigEndian.Uint64(expiryKey)), expiryKey[8:]
+}
@@ -0,0 +1,75 @@
+package disk
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Maximum number of keys deleted within a single transaction, so that writes are not blocked for too long.
+const sweepBatchSize = 1000
+
+// Delete expired data in the given interval until the context is done.
+func (a diskAdapter) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Errors are left for the next sweep.
+		a.sweepExpired(ctx)
+	}
+}
+
+func (a diskAdapter) sweepExpired(ctx context.Context) error {
+	for ctx.Err() == nil {
+		swept := 0
+
+		err := a.db.Update(func(tx *bolt.Tx) error {
+			now := time.Now().UnixNano()
+
+			expiries := tx.Bucket(expiriesBucket)
+
+			expiryKeys := make([][]byte, 0)
+			c := expiries.Cursor()
+			for k, _ := c.First(); k != nil && len(expiryKeys) < sweepBatchSize; k, _ = c.Next() {
+				if expiresAt, _ := decodeExpiryKey(k); !isExpired(expiresAt, now) {
+					break
+				}
+
+				expiryKeys = append(expiryKeys, bytes.Clone(k))
+			}
+
+			for _, expiryKey := range expiryKeys {
+				_, key := decodeExpiryKey(expiryKey)
+				if err := removeExpired(tx, key, now); err != nil {
+					return err
+				}
+
+				// Not left behind even if it no longer matches the entry.
+				if err := expiries.Delete(expiryKey); err != nil {
+					return err
+				}
+			}
+
+			swept = len(expiryKeys)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if swept < sweepBatchSize {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
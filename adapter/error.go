@@ -6,4 +6,6 @@ var (
 	ErrNotFound     = errors.New("wracha: not found")
 	ErrFailedLock   = errors.New("wracha: failed lock")
 	ErrFailedUnlock = errors.New("wracha: failed unlock")
+	ErrFailedExtend = errors.New("wracha: failed extend")
+	ErrNotSupported = errors.New("wracha: not supported")
 )
@@ -6,8 +6,12 @@ import (
 	"time"
 
 	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/fence"
+	"github.com/ezraisw/wracha/adapter/util/glob"
 	"github.com/ezraisw/wracha/adapter/util/mutex"
 	"github.com/ezraisw/wracha/adapter/util/mutex/redislock"
+	"github.com/ezraisw/wracha/adapter/util/mutex/scripted"
+	"github.com/ezraisw/wracha/adapter/util/script"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -19,18 +23,36 @@ type goredisAdapter struct {
 	multiMutex *mutex.MultiMutex
 }
 
-const DefaultLockTTL = 8 * time.Minute
+const DefaultLockTTL = 8 * time.Second
+
+// Locks of the deprecated Lock and Unlock are never extended, so they keep the previous default.
+const defaultMultiMutexLockTTL = 8 * time.Minute
+
+var (
+	tagScript       = redis.NewScript(script.Tag)
+	setFencedScript = redis.NewScript(script.SetFenced)
+)
+
+// Number of keys deleted in a single round trip when deleting a tag.
+const tagDeleteBatchSize = 500
+
+// Number of keys hinted to be returned by each SCAN when deleting a prefix.
+const scanCount = 500
 
 func NewAdapter(client redis.UniversalClient) adapter.Adapter {
-	return NewAdapterWithLockTTL(client, DefaultLockTTL)
+	return newAdapter(client, DefaultLockTTL, defaultMultiMutexLockTTL)
 }
 
 func NewAdapterWithLockTTL(client redis.UniversalClient, lockTtl time.Duration) adapter.Adapter {
+	return newAdapter(client, lockTtl, lockTtl)
+}
+
+func newAdapter(client redis.UniversalClient, lockTtl time.Duration, multiMutexLockTtl time.Duration) *goredisAdapter {
 	return &goredisAdapter{
 		client: client,
-		locker: redislock.NewLocker(client, lockTtl),
+		locker: scripted.NewLocker(lockScripts{client: client}, lockTtl),
 
-		multiMutex: mutex.NewMultiMutex(redislock.NewMutexFactory(client, lockTtl)),
+		multiMutex: mutex.NewMultiMutex(redislock.NewMutexFactory(client, multiMutexLockTtl)),
 	}
 }
 
@@ -60,10 +82,155 @@ func (a goredisAdapter) Set(ctx context.Context, key string, ttl time.Duration,
 	return a.client.Set(ctx, key, data, ttl).Err()
 }
 
+func (a goredisAdapter) SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error) {
+	keys := []string{key, fence.Key(key)}
+	return setFencedScript.Run(ctx, a.client, keys, data, ttl.Milliseconds(), token).Bool()
+}
+
+func (a goredisAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	// Pipelined GET is used instead of MGET since keys might be spread across cluster slots.
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := a.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = p.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	datas := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		datas[i] = data
+	}
+
+	return datas, nil
+}
+
+func (a goredisAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	_, err := a.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, item := range items {
+			p.Set(ctx, item.Key, item.Data, item.TTL)
+		}
+		return nil
+	})
+	return err
+}
+
+func (a goredisAdapter) Tag(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	for _, tag := range tags {
+		if err := tagScript.Run(ctx, a.client, []string{tag}, ttl.Milliseconds(), key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a goredisAdapter) DeleteTag(ctx context.Context, tag string) error {
+	keys, err := a.client.SMembers(ctx, tag).Result()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(keys); start += tagDeleteBatchSize {
+		batch := keys[start:min(start+tagDeleteBatchSize, len(keys))]
+
+		// Delete keys one by one since they might be spread across cluster slots.
+		// Only remove the deleted keys from the tag, as other keys might have been associated in the meantime.
+		_, err := a.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for _, key := range batch {
+				p.Unlink(ctx, key)
+			}
+
+			members := make([]any, len(batch))
+			for i, key := range batch {
+				members[i] = key
+			}
+			p.SRem(ctx, tag, members...)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a goredisAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return a.client.Incr(ctx, key).Result()
+	}
+
+	var cmd *redis.IntCmd
+	_, err := a.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		cmd = p.Incr(ctx, key)
+		p.PExpire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.Val(), nil
+}
+
 func (a goredisAdapter) Delete(ctx context.Context, key string) error {
 	return a.client.Del(ctx, key).Err()
 }
 
+func (a goredisAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	// Each master has to be scanned separately in a cluster.
+	if cc, ok := a.client.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return deletePrefix(ctx, client, prefix)
+		})
+	}
+
+	return deletePrefix(ctx, a.client, prefix)
+}
+
+func deletePrefix(ctx context.Context, client redis.Cmdable, prefix string) error {
+	match := glob.Escape(prefix) + "*"
+
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			// Delete keys one by one since they might be spread across cluster slots.
+			_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+				for _, key := range keys {
+					p.Unlink(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 // Deprecated
 func (a goredisAdapter) Lock(ctx context.Context, key string) error {
 	return a.multiMutex.Lock(ctx, key)
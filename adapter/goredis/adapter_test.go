@@ -0,0 +1,114 @@
+package goredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/fence"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+const lockKey = "lock###testing###testing-key"
+
+func newTestClient(t *testing.T, mr *miniredis.Miniredis) redis.UniversalClient {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestLockLeaseExpired(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := newTestClient(t, mr)
+
+	a := NewAdapterWithLockTTL(client, time.Second)
+	fa := a.(adapter.FencingAdapter)
+	ctx := context.Background()
+
+	stale, err := a.ObtainLock(ctx, lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The action of the first holder outlives its lease.
+	mr.FastForward(2 * time.Second)
+
+	fresh, err := a.ObtainLock(ctx, lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	staleToken := stale.(adapter.FencedLock).Token()
+	freshToken := fresh.(adapter.FencedLock).Token()
+	assert.Greater(t, freshToken, staleToken)
+
+	ok, err := fa.SetFenced(ctx, "testing-key", 0, []byte("fresh"), freshToken)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = fa.SetFenced(ctx, "testing-key", 0, []byte("stale"), staleToken)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	data, err := a.Get(ctx, "testing-key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("fresh"), data)
+
+	// The first holder must not be able to extend or release the lock of the second holder.
+	assert.ErrorIs(t, stale.(adapter.ExtendableLock).Extend(ctx), adapter.ErrFailedExtend)
+	assert.ErrorIs(t, stale.Release(ctx), adapter.ErrFailedUnlock)
+	assert.True(t, mr.Exists(lockKey))
+
+	assert.NoError(t, fresh.(adapter.ExtendableLock).Extend(ctx))
+	assert.NoError(t, fresh.Release(ctx))
+	assert.False(t, mr.Exists(lockKey))
+}
+
+func TestLockCounterLost(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := newTestClient(t, mr)
+
+	a := NewAdapter(client)
+	ctx := context.Background()
+
+	first, err := a.ObtainLock(ctx, lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, first.Release(ctx))
+
+	// Tokens must keep increasing even if the counter is evicted.
+	mr.Del(fence.CounterKey(lockKey))
+	time.Sleep(time.Millisecond)
+
+	second, err := a.ObtainLock(ctx, lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, second.Release(ctx))
+
+	assert.Greater(t, second.(adapter.FencedLock).Token(), first.(adapter.FencedLock).Token())
+}
+
+func TestLockHeld(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := newTestClient(t, mr)
+
+	a := NewAdapter(client)
+
+	lock, err := a.ObtainLock(context.Background(), lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = a.ObtainLock(ctx, lockKey)
+	assert.ErrorIs(t, err, adapter.ErrFailedLock)
+
+	assert.NoError(t, lock.Release(context.Background()))
+}
@@ -0,0 +1,98 @@
+package goredis
+
+import (
+	"context"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/bus"
+	"github.com/redis/go-redis/v9"
+)
+
+const DefaultBusChannel = "wracha:invalidations"
+
+const (
+	// Maximum duration to wait for the subscription to be confirmed.
+	subscribeTimeout = 5 * time.Second
+
+	// Interval of pings keeping the subscription alive. Connections failing to reply are restored by the client.
+	healthCheckInterval = 30 * time.Second
+)
+
+type goredisBus struct {
+	client  redis.UniversalClient
+	channel string
+	codec   *bus.Codec
+}
+
+func NewBus(client redis.UniversalClient) adapter.InvalidationBus {
+	return NewBusWithChannel(client, DefaultBusChannel)
+}
+
+func NewBusWithChannel(client redis.UniversalClient, channel string) adapter.InvalidationBus {
+	return &goredisBus{
+		client:  client,
+		channel: channel,
+		codec:   bus.NewCodec(),
+	}
+}
+
+func (b goredisBus) Publish(ctx context.Context, invalidation adapter.Invalidation) error {
+	return b.client.Publish(ctx, b.channel, b.codec.Encode(invalidation)).Err()
+}
+
+func (b goredisBus) Subscribe(ctx context.Context, handler adapter.InvalidationHandler) error {
+	ps := b.client.Subscribe(ctx, b.channel)
+
+	// Failures of the initial subscription are only surfaced on receive.
+	if _, err := ps.ReceiveTimeout(ctx, subscribeTimeout); err != nil {
+		ps.Close()
+		return err
+	}
+
+	go b.receive(ctx, handler, ps)
+	go b.codec.WatchGaps(ctx, handler)
+
+	return nil
+}
+
+// Receive messages until the context is done.
+func (b goredisBus) receive(ctx context.Context, handler adapter.InvalidationHandler, ps *redis.PubSub) {
+	defer ps.Close()
+
+	// Receiving blocks regardless of the context, so messages are received through the channel instead.
+	ch := ps.ChannelWithSubscriptions(redis.WithChannelHealthCheckInterval(healthCheckInterval))
+
+	for {
+		var msg any
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			// Closed along with the client.
+			if !ok {
+				return
+			}
+			msg = m
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Subscribed again after reconnecting, flush to cover the messages published in between.
+			handler.Flush(ctx)
+
+		case *redis.Message:
+			invalidation, foreign, missed, err := b.codec.Decode(msg.Payload)
+			if err != nil || !foreign {
+				continue
+			}
+
+			if missed {
+				handler.Flush(ctx)
+				continue
+			}
+
+			handler.Invalidate(ctx, invalidation)
+		}
+	}
+}
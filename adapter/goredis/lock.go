@@ -0,0 +1,33 @@
+package goredis
+
+import (
+	"context"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter/util/fence"
+	"github.com/ezraisw/wracha/adapter/util/script"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	obtainFencedLockScript = redis.NewScript(script.ObtainFencedLock)
+	releaseLockScript      = redis.NewScript(script.ReleaseLock)
+	extendLockScript       = redis.NewScript(script.ExtendLock)
+)
+
+type lockScripts struct {
+	client redis.UniversalClient
+}
+
+func (s lockScripts) Obtain(ctx context.Context, key string, value string, ttl time.Duration) (int64, error) {
+	keys := []string{key, fence.CounterKey(key)}
+	return obtainFencedLockScript.Run(ctx, s.client, keys, value, ttl.Milliseconds(), fence.CounterTTL.Milliseconds()).Int64()
+}
+
+func (s lockScripts) Release(ctx context.Context, key string, value string) (bool, error) {
+	return releaseLockScript.Run(ctx, s.client, []string{key}, value).Bool()
+}
+
+func (s lockScripts) Extend(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return extendLockScript.Run(ctx, s.client, []string{key}, value, ttl.Milliseconds()).Bool()
+}
@@ -0,0 +1,102 @@
+package goredis
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karlseguin/ccache/v2"
+)
+
+// Local copies of data read from Redis, evicted on invalidations sent by Redis.
+type nearCache struct {
+	mu      sync.Mutex
+	cache   *ccache.Cache
+	ttl     time.Duration
+	pending map[string]*pendingRead
+}
+
+// Reads in progress for a key. Data read is discarded if the key is invalidated before the read is done,
+// since the invalidation might concern a write which happened after the read.
+type pendingRead struct {
+	refs  int
+	dirty bool
+}
+
+func newNearCache(ttl time.Duration, maxSize int64) *nearCache {
+	cfg := ccache.Configure()
+	if maxSize > 0 {
+		cfg = cfg.MaxSize(maxSize)
+	}
+
+	return &nearCache{
+		cache:   ccache.New(cfg),
+		ttl:     ttl,
+		pending: make(map[string]*pendingRead),
+	}
+}
+
+func (c *nearCache) get(key string) ([]byte, bool) {
+	item := c.cache.Get(key)
+	if item == nil || item.Expired() {
+		return nil, false
+	}
+
+	// Ignore casting errors.
+	return item.Value().([]byte), true
+}
+
+func (c *nearCache) begin(key string) *pendingRead {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[key]
+	if !ok {
+		p = &pendingRead{}
+		c.pending[key] = p
+	}
+	p.refs++
+
+	return p
+}
+
+// Finish the read, keeping the data unless the key has been invalidated meanwhile. Nil data is not kept.
+func (c *nearCache) end(key string, p *pendingRead, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if data != nil && !p.dirty {
+		c.cache.Set(key, data, c.ttl)
+	}
+
+	p.refs--
+	if p.refs == 0 {
+		delete(c.pending, key)
+	}
+}
+
+func (c *nearCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache.Delete(key)
+	if p, ok := c.pending[key]; ok {
+		p.dirty = true
+	}
+}
+
+func (c *nearCache) invalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cache.DeletePrefix(prefix)
+	for key, p := range c.pending {
+		if strings.HasPrefix(key, prefix) {
+			p.dirty = true
+		}
+	}
+}
+
+func (c *nearCache) flush() {
+	c.invalidatePrefix("")
+}
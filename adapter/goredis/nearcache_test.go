@@ -0,0 +1,123 @@
+package goredis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNearCache(t *testing.T) *nearCache {
+	c := newNearCache(time.Minute, 0)
+	t.Cleanup(c.cache.Stop)
+	return c
+}
+
+func TestNearCacheRead(t *testing.T) {
+	c := newTestNearCache(t)
+
+	p := c.begin("key")
+	c.end("key", p, []byte("data"))
+
+	data, ok := c.get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("data"), data)
+	assert.Empty(t, c.pending)
+}
+
+func TestNearCacheMissingRead(t *testing.T) {
+	c := newTestNearCache(t)
+
+	p := c.begin("key")
+	c.end("key", p, nil)
+
+	_, ok := c.get("key")
+	assert.False(t, ok)
+}
+
+func TestNearCacheInvalidatedDuringRead(t *testing.T) {
+	c := newTestNearCache(t)
+
+	p := c.begin("key")
+	// The invalidation might concern a write made after the data was read.
+	c.invalidate("key")
+	c.end("key", p, []byte("data"))
+
+	_, ok := c.get("key")
+	assert.False(t, ok)
+	assert.Empty(t, c.pending)
+}
+
+func TestNearCacheInvalidatedBeforeRead(t *testing.T) {
+	c := newTestNearCache(t)
+
+	c.invalidate("key")
+	p := c.begin("key")
+	c.end("key", p, []byte("data"))
+
+	data, ok := c.get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("data"), data)
+}
+
+func TestNearCacheOverlappingReads(t *testing.T) {
+	c := newTestNearCache(t)
+
+	first := c.begin("key")
+	second := c.begin("key")
+	assert.Same(t, first, second)
+
+	c.invalidate("key")
+
+	// Neither read can tell whether it happened before the invalidated write.
+	c.end("key", first, []byte("first"))
+	_, ok := c.get("key")
+	assert.False(t, ok)
+	assert.Contains(t, c.pending, "key")
+
+	c.end("key", second, []byte("second"))
+	_, ok = c.get("key")
+	assert.False(t, ok)
+	assert.Empty(t, c.pending)
+
+	// Reads starting afterwards are kept again.
+	third := c.begin("key")
+	c.end("key", third, []byte("third"))
+
+	data, ok := c.get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("third"), data)
+}
+
+func TestNearCacheInvalidatedPrefixDuringRead(t *testing.T) {
+	c := newTestNearCache(t)
+
+	p := c.begin("prefix###key")
+	other := c.begin("other###key")
+	c.invalidatePrefix("prefix###")
+	c.end("prefix###key", p, []byte("data"))
+	c.end("other###key", other, []byte("data"))
+
+	_, ok := c.get("prefix###key")
+	assert.False(t, ok)
+
+	_, ok = c.get("other###key")
+	assert.True(t, ok)
+}
+
+func TestNearCacheFlushedDuringRead(t *testing.T) {
+	c := newTestNearCache(t)
+
+	kept := c.begin("kept")
+	c.end("kept", kept, []byte("data"))
+
+	p := c.begin("key")
+	c.flush()
+	c.end("key", p, []byte("data"))
+
+	_, ok := c.get("key")
+	assert.False(t, ok)
+
+	_, ok = c.get("kept")
+	assert.False(t, ok)
+}
@@ -0,0 +1,407 @@
+package goredis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/redis/go-redis/v9"
+)
+
+type TrackingMode int
+
+const (
+	// Track keys read by the adapter.
+	TrackingDefault TrackingMode = iota
+
+	// Track every key starting with the given prefixes, whether read by the adapter or not.
+	TrackingBroadcast
+
+	// Track keys read by the adapter, requested explicitly along with every read.
+	TrackingOptIn
+)
+
+type TrackingOptions struct {
+	Mode TrackingMode
+
+	// Prefixes of keys tracked in broadcast mode. Every key is tracked if empty.
+	Prefixes []string
+
+	// Maximum TTL of data kept locally. Defaults to DefaultTrackingLocalTTL.
+	LocalTTL time.Duration
+
+	// Maximum number of keys kept locally. Defaults to the default of ccache.
+	MaxSize int64
+}
+
+const DefaultTrackingLocalTTL = 1 * time.Minute
+
+// Channel of invalidations sent by Redis to clients redirecting their tracking.
+const invalidateChannel = "__redis__:invalidate"
+
+const (
+	// Delay before subscribing again after the connection fails.
+	reconnectDelay = 1 * time.Second
+
+	// Delay before closing a reader replaced after reconnecting, allowing reads in progress to finish.
+	readerCloseDelay = 10 * time.Second
+)
+
+var errResubscribed = errors.New("wracha: invalidation subscription reconnected")
+
+type trackingAdapter struct {
+	*goredisAdapter
+
+	opt  *redis.Options
+	o    TrackingOptions
+	near *nearCache
+
+	// Client whose connections have tracking enabled, redirected to the current subscription.
+	// Nil while not subscribed, in which case data is not kept locally.
+	reader *atomic.Pointer[redis.Client]
+}
+
+// Create an adapter keeping data read from Redis locally, using server-assisted client-side caching (CLIENT TRACKING).
+//
+// Invalidations are received through a dedicated connection to which tracking is redirected, as go-redis does not
+// surface RESP3 push messages. Data kept locally is flushed whenever the connection is lost.
+// The adapter owns its clients, which are closed once the context is done. Redis Cluster is not supported.
+func NewTrackingAdapter(ctx context.Context, opt *redis.Options, options TrackingOptions) adapter.Adapter {
+	return newTrackingAdapter(ctx, opt, options, DefaultLockTTL, defaultMultiMutexLockTTL)
+}
+
+func NewTrackingAdapterWithLockTTL(ctx context.Context, opt *redis.Options, options TrackingOptions, lockTtl time.Duration) adapter.Adapter {
+	return newTrackingAdapter(ctx, opt, options, lockTtl, lockTtl)
+}
+
+func newTrackingAdapter(ctx context.Context, opt *redis.Options, options TrackingOptions, lockTtl time.Duration, multiMutexLockTtl time.Duration) adapter.Adapter {
+	if options.LocalTTL <= 0 {
+		options.LocalTTL = DefaultTrackingLocalTTL
+	}
+
+	a := &trackingAdapter{
+		goredisAdapter: newAdapter(redis.NewClient(opt), lockTtl, multiMutexLockTtl),
+		opt:            opt,
+		o:              options,
+		near:           newNearCache(options.LocalTTL, options.MaxSize),
+		reader:         &atomic.Pointer[redis.Client]{},
+	}
+	go a.track(ctx)
+
+	return a
+}
+
+func (a *trackingAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	if _, ok := a.near.get(key); ok {
+		return true, nil
+	}
+
+	return a.goredisAdapter.Exists(ctx, key)
+}
+
+func (a *trackingAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	datas, err := a.GetMany(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+
+	if datas[0] == nil {
+		return nil, adapter.ErrNotFound
+	}
+
+	return datas[0], nil
+}
+
+func (a *trackingAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	datas := make([][]byte, len(keys))
+
+	missingKeys := make([]string, 0, len(keys))
+	missingIdxs := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if data, ok := a.near.get(key); ok {
+			datas[i] = data
+			continue
+		}
+
+		missingKeys = append(missingKeys, key)
+		missingIdxs = append(missingIdxs, i)
+	}
+
+	if len(missingKeys) == 0 {
+		return datas, nil
+	}
+
+	reader := a.reader.Load()
+	if reader == nil {
+		remoteDatas, err := a.goredisAdapter.GetMany(ctx, missingKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, data := range remoteDatas {
+			datas[missingIdxs[j]] = data
+		}
+		return datas, nil
+	}
+
+	reads := make([]*pendingRead, len(missingKeys))
+	for j, key := range missingKeys {
+		reads[j] = a.near.begin(key)
+	}
+
+	remoteDatas, err := a.read(ctx, reader, missingKeys)
+	for j, key := range missingKeys {
+		var data []byte
+		if err == nil {
+			data = remoteDatas[j]
+		}
+		a.near.end(key, reads[j], data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for j, data := range remoteDatas {
+		datas[missingIdxs[j]] = data
+	}
+
+	return datas, nil
+}
+
+// Read the keys through the reader, such that they are tracked.
+func (a *trackingAdapter) read(ctx context.Context, reader *redis.Client, keys []string) ([][]byte, error) {
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := reader.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			// Only the command right after is tracked.
+			if a.o.Mode == TrackingOptIn {
+				pipe.Do(ctx, "CLIENT", "CACHING", "YES")
+			}
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	datas := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		datas[i] = data
+	}
+
+	return datas, nil
+}
+
+// Writes are invalidated locally right away, instead of waiting for the invalidation from Redis.
+func (a *trackingAdapter) Set(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+	defer a.near.invalidate(key)
+	return a.goredisAdapter.Set(ctx, key, ttl, data)
+}
+
+func (a *trackingAdapter) SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error) {
+	defer a.near.invalidate(key)
+	return a.goredisAdapter.SetFenced(ctx, key, ttl, data, token)
+}
+
+func (a *trackingAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	defer func() {
+		for _, item := range items {
+			a.near.invalidate(item.Key)
+		}
+	}()
+	return a.goredisAdapter.SetMany(ctx, items)
+}
+
+func (a *trackingAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	defer a.near.invalidate(key)
+	return a.goredisAdapter.Incr(ctx, key, ttl)
+}
+
+func (a *trackingAdapter) Delete(ctx context.Context, key string) error {
+	defer a.near.invalidate(key)
+	return a.goredisAdapter.Delete(ctx, key)
+}
+
+func (a *trackingAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	defer a.near.invalidatePrefix(prefix)
+	return a.goredisAdapter.DeletePrefix(ctx, prefix)
+}
+
+func (a *trackingAdapter) DeleteTag(ctx context.Context, tag string) error {
+	// Keys of the tag are only known by Redis.
+	defer a.near.flush()
+	return a.goredisAdapter.DeleteTag(ctx, tag)
+}
+
+// Keep subscribing to invalidations until the context is done.
+func (a *trackingAdapter) track(ctx context.Context) {
+	defer func() {
+		if reader := a.reader.Swap(nil); reader != nil {
+			reader.Close()
+		}
+		a.goredisAdapter.client.Close()
+		a.near.cache.Stop()
+	}()
+
+	for {
+		a.subscribe(ctx)
+
+		// Data read through the previous reader is no longer invalidated.
+		if reader := a.reader.Swap(nil); reader != nil {
+			time.AfterFunc(readerCloseDelay, func() { reader.Close() })
+		}
+		a.near.flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// Receive invalidations until the connection fails or the context is done.
+func (a *trackingAdapter) subscribe(ctx context.Context) error {
+	name, err := newClientName()
+	if err != nil {
+		return err
+	}
+
+	subOpt := *a.opt
+	subOpt.ClientName = name
+
+	sub := redis.NewClient(&subOpt)
+	defer sub.Close()
+
+	ps := sub.Subscribe(ctx, invalidateChannel)
+	defer ps.Close()
+
+	// Receiving blocks regardless of the context, so the subscription is closed once the context is done.
+	// The channel of go-redis is not used, as it drops messages it fails to parse, such as the invalidation sent on flushes.
+	stop := context.AfterFunc(ctx, func() { ps.Close() })
+	defer stop()
+
+	// The subscription is confirmed once the connection is established.
+	if _, err := ps.ReceiveTimeout(ctx, subscribeTimeout); err != nil {
+		return err
+	}
+
+	id, err := a.findClientID(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	a.reader.Store(a.newReader(id))
+
+	pinged := false
+	for {
+		msg, err := ps.ReceiveTimeout(ctx, healthCheckInterval)
+		if err != nil {
+			// Nothing has been received for a while, check whether the connection is still alive.
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && !pinged {
+				if err := ps.Ping(ctx); err != nil {
+					return err
+				}
+				pinged = true
+				continue
+			}
+
+			return err
+		}
+		pinged = false
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Reconnected as a different client, which tracking is no longer redirected to.
+			return errResubscribed
+
+		case *redis.Message:
+			for _, key := range msg.PayloadSlice {
+				a.near.invalidate(key)
+			}
+		}
+	}
+}
+
+func (a *trackingAdapter) findClientID(ctx context.Context, name string) (int64, error) {
+	list, err := a.goredisAdapter.client.ClientList(ctx).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(list, "\n") {
+		var id, clientName string
+		for _, field := range strings.Fields(line) {
+			if v, ok := strings.CutPrefix(field, "id="); ok {
+				id = v
+			} else if v, ok := strings.CutPrefix(field, "name="); ok {
+				clientName = v
+			}
+		}
+
+		if clientName == name {
+			return strconv.ParseInt(id, 10, 64)
+		}
+	}
+
+	return 0, errors.New("wracha: invalidation subscription not found")
+}
+
+// Create a client whose connections have tracking enabled, redirected to the client of the given id.
+func (a *trackingAdapter) newReader(redirectId int64) *redis.Client {
+	args := []any{"CLIENT", "TRACKING", "ON", "REDIRECT", redirectId}
+	switch a.o.Mode {
+	case TrackingBroadcast:
+		args = append(args, "BCAST")
+		for _, prefix := range a.o.Prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+	case TrackingOptIn:
+		args = append(args, "OPTIN")
+	}
+
+	readerOpt := *a.opt
+	readerOpt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if a.opt.OnConnect != nil {
+			if err := a.opt.OnConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+
+		cmd := redis.NewStatusCmd(ctx, args...)
+		if err := cn.Process(ctx, cmd); err != nil {
+			return err
+		}
+		return cmd.Err()
+	}
+
+	return redis.NewClient(&readerOpt)
+}
+
+func newClientName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "wracha-tracking-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,294 @@
+package goredis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRedisClient struct {
+	id   int64
+	name string
+	conn net.Conn
+
+	// Id of the client receiving invalidations of keys read by this client, or zero if not tracking.
+	redirect   int64
+	subscribed bool
+}
+
+// Minimal Redis server speaking the subset of RESP2 used by the tracking adapter,
+// sending invalidations of tracked keys to the subscription tracking is redirected to.
+type fakeRedis struct {
+	mu      sync.Mutex
+	data    map[string]string
+	clients map[int64]*fakeRedisClient
+	tracked map[string]map[int64]struct{}
+	nextId  int64
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &fakeRedis{
+		data:    make(map[string]string),
+		clients: make(map[int64]*fakeRedisClient),
+		tracked: make(map[string]map[int64]struct{}),
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s, l.Addr().String()
+}
+
+func (s *fakeRedis) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, c := range s.clients {
+		if c.subscribed {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	s.mu.Lock()
+	s.nextId++
+	c := &fakeRedisClient{id: s.nextId, conn: conn}
+	s.clients[c.id] = c
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c.id)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		reply := s.handle(c, args)
+		_, err = io.WriteString(conn, reply)
+		s.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) handle(c *fakeRedisClient, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "CLIENT":
+		switch strings.ToUpper(args[1]) {
+		case "SETNAME":
+			c.name = args[2]
+		case "LIST":
+			lines := make([]string, 0, len(s.clients))
+			for _, other := range s.clients {
+				lines = append(lines, fmt.Sprintf("id=%d addr=%s name=%s", other.id, other.conn.RemoteAddr(), other.name))
+			}
+			return bulk(strings.Join(lines, "\n") + "\n")
+		case "TRACKING":
+			redirect, _ := strconv.ParseInt(args[4], 10, 64)
+			c.redirect = redirect
+		}
+		return "+OK\r\n"
+
+	case "SUBSCRIBE":
+		c.subscribed = true
+		return "*3\r\n" + bulk("subscribe") + bulk(args[1]) + ":1\r\n"
+
+	case "PING":
+		if c.subscribed {
+			return "*2\r\n" + bulk("pong") + bulk("")
+		}
+		return "+PONG\r\n"
+
+	case "GET":
+		if c.redirect != 0 {
+			if s.tracked[args[1]] == nil {
+				s.tracked[args[1]] = make(map[int64]struct{})
+			}
+			s.tracked[args[1]][c.redirect] = struct{}{}
+		}
+
+		data, ok := s.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(data)
+
+	case "SET":
+		s.data[args[1]] = args[2]
+		s.invalidate(args[1])
+		return "+OK\r\n"
+
+	case "DEL":
+		count := 0
+		for _, key := range args[1:] {
+			if _, ok := s.data[key]; ok {
+				delete(s.data, key)
+				count++
+			}
+			s.invalidate(key)
+		}
+		return ":" + strconv.Itoa(count) + "\r\n"
+
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func (s *fakeRedis) invalidate(key string) {
+	for id := range s.tracked[key] {
+		if c, ok := s.clients[id]; ok && c.subscribed {
+			io.WriteString(c.conn, "*3\r\n"+bulk("message")+bulk(invalidateChannel)+"*1\r\n"+bulk(key))
+		}
+	}
+	delete(s.tracked, key)
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+
+	return args, nil
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func newTrackingTestAdapter(t *testing.T, ctx context.Context, addr string) *trackingAdapter {
+	a := NewTrackingAdapter(ctx, &redis.Options{Addr: addr}, TrackingOptions{}).(*trackingAdapter)
+
+	// Data is only kept locally once subscribed.
+	if !assert.Eventually(t, func() bool { return a.reader.Load() != nil }, time.Second, 10*time.Millisecond) {
+		t.FailNow()
+	}
+	return a
+}
+
+func TestTrackingEvictsOnRemoteWrite(t *testing.T) {
+	_, addr := startFakeRedis(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := newTrackingTestAdapter(t, ctx, addr)
+
+	writer := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { writer.Close() })
+
+	if !assert.NoError(t, writer.Set(ctx, "key", "first", 0).Err()) {
+		return
+	}
+
+	data, err := a.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), data)
+
+	_, ok := a.near.get("key")
+	assert.True(t, ok)
+
+	// Written by another client, which Redis tells the subscription about.
+	if !assert.NoError(t, writer.Set(ctx, "key", "second", 0).Err()) {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		_, ok := a.near.get("key")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+
+	data, err = a.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	// Deleted by another client.
+	if !assert.NoError(t, writer.Del(ctx, "key").Err()) {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		_, err := a.Get(ctx, "key")
+		return err == adapter.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTrackingCancelled(t *testing.T) {
+	s, addr := startFakeRedis(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a := newTrackingTestAdapter(t, ctx, addr)
+	assert.Equal(t, 1, s.subscribers())
+
+	reader := a.reader.Load()
+	assert.NoError(t, reader.Ping(context.Background()).Err())
+
+	cancel()
+
+	// The subscription is closed without waiting for another message, and local data is no longer kept.
+	assert.Eventually(t, func() bool {
+		return s.subscribers() == 0 && a.reader.Load() == nil
+	}, time.Second, 10*time.Millisecond)
+}
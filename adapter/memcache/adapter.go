@@ -0,0 +1,202 @@
+package memcache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/mutex"
+)
+
+type memcacheAdapter struct {
+	client *memcache.Client
+	locker mutex.Locker
+
+	// Deprecated
+	multiMutex *mutex.MultiMutex
+}
+
+const DefaultLockTTL = 8 * time.Second
+
+// Expirations longer than this are interpreted by memcached as absolute unix time instead of relative seconds.
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// Maximum length of keys accepted by memcached.
+const maxKeyLength = 250
+
+func NewAdapter(client *memcache.Client) adapter.Adapter {
+	return NewAdapterWithLockTTL(client, DefaultLockTTL)
+}
+
+func NewAdapterWithLockTTL(client *memcache.Client, lockTtl time.Duration) adapter.Adapter {
+	return &memcacheAdapter{
+		client: client,
+		locker: NewLocker(client, lockTtl),
+
+		multiMutex: mutex.NewMultiMutex(NewMutexFactory(client, lockTtl)),
+	}
+}
+
+func (a memcacheAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := a.client.Get(getKey(key))
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (a memcacheAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	item, err := a.client.Get(getKey(key))
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			err = adapter.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return item.Value, nil
+}
+
+func (a memcacheAdapter) Set(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+	return a.client.Set(&memcache.Item{
+		Key:        getKey(key),
+		Value:      data,
+		Expiration: getExpiration(ttl),
+	})
+}
+
+func (a memcacheAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	mcKeys := make([]string, len(keys))
+	for i, key := range keys {
+		mcKeys[i] = getKey(key)
+	}
+
+	items, err := a.client.GetMulti(mcKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	datas := make([][]byte, len(keys))
+	for i, mcKey := range mcKeys {
+		if item, ok := items[mcKey]; ok {
+			datas[i] = item.Value
+		}
+	}
+
+	return datas, nil
+}
+
+func (a memcacheAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	for _, item := range items {
+		if err := a.Set(ctx, item.Key, item.TTL, item.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a memcacheAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	mcKey := getKey(key)
+
+	for {
+		count, err := a.client.Increment(mcKey, 1)
+		if err == nil {
+			// Increments retain the expiration of the counter.
+			if ttl > 0 {
+				if err := a.client.Touch(mcKey, getExpiration(ttl)); err != nil {
+					return 0, err
+				}
+			}
+
+			return int64(count), nil
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, err
+		}
+
+		err = a.client.Add(&memcache.Item{
+			Key:        mcKey,
+			Value:      []byte("1"),
+			Expiration: getExpiration(ttl),
+		})
+		if err == nil {
+			return 1, nil
+		}
+
+		// Created by another client meanwhile, increment it instead.
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, err
+		}
+	}
+}
+
+func (a memcacheAdapter) Delete(ctx context.Context, key string) error {
+	if err := a.client.Delete(getKey(key)); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return err
+	}
+	return nil
+}
+
+// Deprecated
+func (a memcacheAdapter) Lock(ctx context.Context, key string) error {
+	return a.multiMutex.Lock(ctx, key)
+}
+
+// Deprecated
+func (a memcacheAdapter) Unlock(ctx context.Context, key string) error {
+	return a.multiMutex.Unlock(ctx, key)
+}
+
+func (a memcacheAdapter) ObtainLock(ctx context.Context, key string) (adapter.Lock, error) {
+	return a.locker.Obtain(ctx, key)
+}
+
+// Keys not accepted by memcached, such as long keys or keys with whitespaces, are replaced with their hash.
+func getKey(key string) string {
+	if isLegalKey(key) {
+		return key
+	}
+
+	hash := sha1.Sum([]byte(key))
+	return "sha1###" + hex.EncodeToString(hash[:])
+}
+
+func isLegalKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Convert the TTL to memcached expiration. Zero means the item does not expire.
+func getExpiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	// Round up, as memcached only has a resolution of seconds.
+	seconds := (ttl + time.Second - 1) / time.Second
+
+	if ttl > maxRelativeExpiration {
+		return int32(time.Now().Add(seconds * time.Second).Unix())
+	}
+
+	return int32(seconds)
+}
@@ -0,0 +1,321 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeItem struct {
+	value     []byte
+	flags     string
+	exptime   int64
+	expiresAt time.Time
+	casId     uint64
+}
+
+// Minimal memcached server speaking the subset of the text protocol used by the adapter.
+type fakeServer struct {
+	mu     sync.Mutex
+	items  map[string]*fakeItem
+	nextId uint64
+}
+
+func startFakeServer(t *testing.T) (*fakeServer, *memcache.Client) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &fakeServer{items: make(map[string]*fakeItem)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s, memcache.New(l.Addr().String())
+}
+
+func (s *fakeServer) item(key string) *fakeItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key)
+}
+
+func (s *fakeServer) get(key string) *fakeItem {
+	item, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+
+	if !item.expiresAt.IsZero() && !time.Now().Before(item.expiresAt) {
+		delete(s.items, key)
+		return nil
+	}
+
+	return item
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		var data []byte
+		switch args[0] {
+		case "set", "add", "cas":
+			n, _ := strconv.Atoi(args[4])
+			data = make([]byte, n+2)
+			if _, err := io.ReadFull(rw, data); err != nil {
+				return
+			}
+			data = data[:n]
+		}
+
+		s.mu.Lock()
+		s.handle(rw, args, data)
+		s.mu.Unlock()
+
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) handle(w *bufio.ReadWriter, args []string, data []byte) {
+	switch args[0] {
+	case "get", "gets":
+		for _, key := range args[1:] {
+			if item := s.get(key); item != nil {
+				fmt.Fprintf(w, "VALUE %s %s %d %d\r\n%s\r\n", key, item.flags, len(item.value), item.casId, item.value)
+			}
+		}
+		fmt.Fprint(w, "END\r\n")
+
+	case "set", "add", "cas":
+		key := args[1]
+		current := s.get(key)
+		switch {
+		case args[0] == "add" && current != nil:
+			fmt.Fprint(w, "NOT_STORED\r\n")
+			return
+		case args[0] == "cas" && current == nil:
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		case args[0] == "cas" && strconv.FormatUint(current.casId, 10) != args[5]:
+			fmt.Fprint(w, "EXISTS\r\n")
+			return
+		}
+
+		exptime, _ := strconv.ParseInt(args[3], 10, 64)
+		s.nextId++
+		s.items[key] = &fakeItem{value: data, flags: args[2], casId: s.nextId}
+		s.setExpiration(s.items[key], exptime)
+		fmt.Fprint(w, "STORED\r\n")
+
+	case "delete":
+		if s.get(args[1]) == nil {
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		}
+		delete(s.items, args[1])
+		fmt.Fprint(w, "DELETED\r\n")
+
+	case "incr":
+		item := s.get(args[1])
+		if item == nil {
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		}
+		value, _ := strconv.ParseUint(string(item.value), 10, 64)
+		delta, _ := strconv.ParseUint(args[2], 10, 64)
+		item.value = []byte(strconv.FormatUint(value+delta, 10))
+		s.nextId++
+		item.casId = s.nextId
+		fmt.Fprintf(w, "%s\r\n", item.value)
+
+	case "touch":
+		item := s.get(args[1])
+		if item == nil {
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		}
+		exptime, _ := strconv.ParseInt(args[2], 10, 64)
+		s.setExpiration(item, exptime)
+		fmt.Fprint(w, "TOUCHED\r\n")
+
+	default:
+		fmt.Fprint(w, "ERROR\r\n")
+	}
+}
+
+// Expirations up to 30 days are relative seconds, and absolute unix time beyond that.
+func (s *fakeServer) setExpiration(item *fakeItem, exptime int64) {
+	item.exptime = exptime
+	switch {
+	case exptime == 0:
+		item.expiresAt = time.Time{}
+	case exptime <= int64(maxRelativeExpiration/time.Second):
+		item.expiresAt = time.Now().Add(time.Duration(exptime) * time.Second)
+	default:
+		item.expiresAt = time.Unix(exptime, 0)
+	}
+}
+
+func TestGetSetDelete(t *testing.T) {
+	_, client := startFakeServer(t)
+	a := NewAdapter(client)
+	ctx := context.Background()
+
+	_, err := a.Get(ctx, "key")
+	assert.ErrorIs(t, err, adapter.ErrNotFound)
+
+	assert.NoError(t, a.Set(ctx, "key", time.Minute, []byte("value")))
+
+	data, err := a.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+
+	exists, err := a.Exists(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, a.Delete(ctx, "key"))
+	assert.NoError(t, a.Delete(ctx, "key"))
+
+	exists, err = a.Exists(ctx, "key")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestIllegalKey(t *testing.T) {
+	_, client := startFakeServer(t)
+	a := NewAdapter(client)
+	ctx := context.Background()
+
+	keys := []string{"key with spaces", strings.Repeat("k", maxKeyLength+1)}
+	for _, key := range keys {
+		assert.NoError(t, a.Set(ctx, key, time.Minute, []byte(key)))
+
+		data, err := a.Get(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(key), data)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	s, client := startFakeServer(t)
+	a := NewAdapter(client)
+	ctx := context.Background()
+
+	assert.NoError(t, a.Set(ctx, "relative", 1500*time.Millisecond, []byte("value")))
+	assert.Equal(t, int64(2), s.item("relative").exptime)
+
+	assert.NoError(t, a.Set(ctx, "absolute", 31*24*time.Hour, []byte("value")))
+	assert.InDelta(t, time.Now().Add(31*24*time.Hour).Unix(), s.item("absolute").exptime, 2)
+
+	assert.NoError(t, a.Set(ctx, "persistent", 0, []byte("value")))
+	assert.Equal(t, int64(0), s.item("persistent").exptime)
+
+	assert.NoError(t, a.Set(ctx, "expiring", time.Second, []byte("value")))
+	assert.Eventually(t, func() bool {
+		_, err := a.Get(ctx, "expiring")
+		return err == adapter.ErrNotFound
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestIncr(t *testing.T) {
+	s, client := startFakeServer(t)
+	a := NewAdapter(client).(adapter.CounterAdapter)
+	ctx := context.Background()
+
+	for i := int64(1); i <= 3; i++ {
+		count, err := a.Incr(ctx, "counter", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, count)
+	}
+	assert.Equal(t, int64(60), s.item("counter").exptime)
+}
+
+func TestLock(t *testing.T) {
+	_, client := startFakeServer(t)
+	a := NewAdapterWithLockTTL(client, 2*time.Second)
+	ctx := context.Background()
+
+	lock, err := a.ObtainLock(ctx, "lock")
+	assert.NoError(t, err)
+
+	// The lease is held, so the second attempt keeps retrying until the context is done.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = a.ObtainLock(timeoutCtx, "lock")
+	assert.ErrorIs(t, err, adapter.ErrFailedLock)
+
+	el, ok := lock.(adapter.ExtendableLock)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, el.TTL())
+	assert.NoError(t, el.Extend(ctx))
+
+	done := make(chan lockResult, 1)
+	go func() {
+		lock, err := a.ObtainLock(ctx, "lock")
+		done <- lockResult{lock, err}
+	}()
+
+	assert.NoError(t, lock.Release(ctx))
+
+	result := <-done
+	assert.NoError(t, result.err)
+
+	// The lease now belongs to the other holder.
+	assert.ErrorIs(t, lock.Release(ctx), adapter.ErrFailedUnlock)
+	assert.ErrorIs(t, el.Extend(ctx), adapter.ErrFailedExtend)
+	assert.NoError(t, result.lock.Release(ctx))
+}
+
+func TestLockExpiry(t *testing.T) {
+	_, client := startFakeServer(t)
+	a := NewAdapterWithLockTTL(client, time.Second)
+	ctx := context.Background()
+
+	_, err := a.ObtainLock(ctx, "lock")
+	assert.NoError(t, err)
+
+	// Never released, but the lease expires.
+	lock, err := a.ObtainLock(ctx, "lock")
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Release(ctx))
+}
+
+type lockResult struct {
+	lock adapter.Lock
+	err  error
+}
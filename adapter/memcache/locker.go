@@ -0,0 +1,138 @@
+package memcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/mutex"
+)
+
+// Same retry strategy as the locks of the goredis adapter.
+const (
+	lockMinBackoff = 16 * time.Millisecond
+	lockMaxBackoff = 4096 * time.Millisecond
+	lockMaxRetries = 32
+)
+
+type memcacheLocker struct {
+	client  *memcache.Client
+	lockTtl time.Duration
+}
+
+// Create a locker using items added only if absent as leases, expiring after the lock TTL.
+func NewLocker(client *memcache.Client, lockTtl time.Duration) mutex.Locker {
+	return &memcacheLocker{
+		client:  client,
+		lockTtl: lockTtl,
+	}
+}
+
+func (lr memcacheLocker) Obtain(ctx context.Context, key string) (mutex.Lock, error) {
+	value, err := newLockValue()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &memcacheLock{
+		client:  lr.client,
+		key:     getKey(key),
+		value:   value,
+		lockTtl: lr.lockTtl,
+	}
+
+	backoff := lockMinBackoff
+	for retries := 0; ; retries++ {
+		err := lr.client.Add(&memcache.Item{
+			Key:        lock.key,
+			Value:      lock.value,
+			Expiration: getExpiration(lr.lockTtl),
+		})
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, memcache.ErrNotStored) || retries >= lockMaxRetries {
+			return nil, adapter.ErrFailedLock
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, adapter.ErrFailedLock
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, lockMaxBackoff)
+	}
+}
+
+type memcacheLock struct {
+	client  *memcache.Client
+	key     string
+	value   []byte
+	lockTtl time.Duration
+}
+
+// Release the lock if it is still held.
+//
+// Memcached has no conditional delete, so a lease expiring right between the check and the delete
+// might release the lock of the next holder.
+func (l memcacheLock) Release(ctx context.Context) error {
+	item, err := l.client.Get(l.key)
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return adapter.ErrFailedUnlock
+		}
+		return err
+	}
+
+	if !bytes.Equal(item.Value, l.value) {
+		return adapter.ErrFailedUnlock
+	}
+
+	if err := l.client.Delete(l.key); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return err
+	}
+	return nil
+}
+
+func (l memcacheLock) Extend(ctx context.Context) error {
+	item, err := l.client.Get(l.key)
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return adapter.ErrFailedExtend
+		}
+		return err
+	}
+
+	if !bytes.Equal(item.Value, l.value) {
+		return adapter.ErrFailedExtend
+	}
+
+	// Only succeeds if the lease has not been replaced since it was read.
+	item.Expiration = getExpiration(l.lockTtl)
+	if err := l.client.CompareAndSwap(item); err != nil {
+		if errors.Is(err, memcache.ErrCASConflict) || errors.Is(err, memcache.ErrNotStored) || errors.Is(err, memcache.ErrCacheMiss) {
+			return adapter.ErrFailedExtend
+		}
+		return err
+	}
+	return nil
+}
+
+func (l memcacheLock) TTL() time.Duration {
+	return l.lockTtl
+}
+
+func newLockValue() ([]byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return []byte(hex.EncodeToString(b)), nil
+}
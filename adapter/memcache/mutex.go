@@ -0,0 +1,61 @@
+package memcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/ezraisw/wracha/adapter/util/mutex"
+)
+
+type memcacheMutexFactory struct {
+	locker mutex.Locker
+}
+
+func NewMutexFactory(client *memcache.Client, lockTtl time.Duration) mutex.MutexFactory {
+	return &memcacheMutexFactory{
+		locker: NewLocker(client, lockTtl),
+	}
+}
+
+func (f memcacheMutexFactory) Make(key string) mutex.Mutex {
+	return &memcacheMutex{
+		locker: f.locker,
+		key:    key,
+	}
+}
+
+type memcacheMutex struct {
+	mu          sync.Mutex
+	currentLock mutex.Lock
+
+	locker mutex.Locker
+	key    string
+}
+
+func (m *memcacheMutex) Lock(ctx context.Context) error {
+	lock, err := m.locker.Obtain(ctx, m.key)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.currentLock = lock
+	return nil
+}
+
+func (m *memcacheMutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.currentLock == nil {
+		return nil
+	}
+
+	err := m.currentLock.Release(ctx)
+	m.currentLock = nil
+	return err
+}
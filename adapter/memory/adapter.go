@@ -2,18 +2,30 @@ package memory
 
 import (
 	"context"
+	"strconv"
+	gosync "sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/fence"
 	"github.com/ezraisw/wracha/adapter/util/mutex"
 	"github.com/ezraisw/wracha/adapter/util/mutex/sync"
 	"github.com/karlseguin/ccache/v2"
 )
 
+// Used in place of TTL for items which should not expire, since ccache does not support it.
+const noExpiry = 100 * 365 * 24 * time.Hour
+
 type memoryAdapter struct {
-	cacheCfg *ccache.Configuration
-	cache    *ccache.Cache
-	locker   mutex.Locker
+	cacheCfg  *ccache.Configuration
+	cache     *ccache.Cache
+	cacheOnce *gosync.Once
+	locker    mutex.Locker
+
+	tags      *tagIndex
+	counterMu *gosync.Mutex
+	tokens    *atomic.Int64
 
 	// Deprecated
 	multiMutex *mutex.MultiMutex
@@ -21,8 +33,12 @@ type memoryAdapter struct {
 
 func NewAdapter() adapter.Adapter {
 	return &memoryAdapter{
-		cacheCfg: ccache.Configure(),
-		locker:   sync.NewLocker(),
+		cacheCfg:  ccache.Configure(),
+		cacheOnce: &gosync.Once{},
+		locker:    sync.NewLocker(),
+		tags:      newTagIndex(),
+		counterMu: &gosync.Mutex{},
+		tokens:    &atomic.Int64{},
 
 		multiMutex: mutex.NewMultiMutex(sync.NewMutexFactory()),
 	}
@@ -30,15 +46,22 @@ func NewAdapter() adapter.Adapter {
 
 func NewAdapterWithConfiguration(cacheCfg *ccache.Configuration) adapter.Adapter {
 	return &memoryAdapter{
-		cacheCfg: cacheCfg,
+		cacheCfg:  cacheCfg,
+		cacheOnce: &gosync.Once{},
+		locker:    sync.NewLocker(),
+		tags:      newTagIndex(),
+		counterMu: &gosync.Mutex{},
+		tokens:    &atomic.Int64{},
+
+		multiMutex: mutex.NewMultiMutex(sync.NewMutexFactory()),
 	}
 }
 
 func (a *memoryAdapter) getCache() *ccache.Cache {
-	// Lazily create the instance.
-	if a.cache == nil {
+	// Lazily create the instance, which might be first used by concurrent callers.
+	a.cacheOnce.Do(func() {
 		a.cache = ccache.New(a.cacheCfg)
-	}
+	})
 
 	return a.cache
 }
@@ -61,12 +84,93 @@ func (a *memoryAdapter) Get(ctx context.Context, key string) ([]byte, error) {
 }
 
 func (a *memoryAdapter) Set(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+	a.getCache().Set(key, data, getTTL(ttl))
+	return nil
+}
+
+func (a *memoryAdapter) SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error) {
+	a.counterMu.Lock()
+	defer a.counterMu.Unlock()
+
+	fenceKey := fence.Key(key)
+
+	// Ignore casting errors.
+	if item := a.getCache().Get(fenceKey); item != nil && !item.Expired() && token < item.Value().(int64) {
+		return false, nil
+	}
+
+	ttl = getTTL(ttl)
+
 	a.getCache().Set(key, data, ttl)
+	a.getCache().Set(fenceKey, token, ttl)
+
+	return true, nil
+}
+
+func (a *memoryAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	datas := make([][]byte, len(keys))
+	for i, key := range keys {
+		item := a.getCache().Get(key)
+		if item == nil || item.Expired() {
+			continue
+		}
+
+		// Ignore casting errors.
+		datas[i] = item.Value().([]byte)
+	}
+
+	return datas, nil
+}
+
+func (a *memoryAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	for _, item := range items {
+		a.getCache().Set(item.Key, item.Data, getTTL(item.TTL))
+	}
 	return nil
 }
 
+func (a *memoryAdapter) Tag(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	a.tags.add(key, getTTL(ttl), tags)
+	return nil
+}
+
+func (a *memoryAdapter) DeleteTag(ctx context.Context, tag string) error {
+	for _, key := range a.tags.remove(tag) {
+		a.getCache().Delete(key)
+	}
+	return nil
+}
+
+func (a *memoryAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	a.counterMu.Lock()
+	defer a.counterMu.Unlock()
+
+	var count int64
+
+	// Retain the remaining TTL of the counter unless specified.
+	if item := a.getCache().Get(key); item != nil && !item.Expired() {
+		// Ignore casting errors.
+		count, _ = strconv.ParseInt(string(item.Value().([]byte)), 10, 64)
+		if ttl <= 0 {
+			ttl = item.TTL()
+		}
+	}
+
+	count++
+	a.getCache().Set(key, []byte(strconv.FormatInt(count, 10)), getTTL(ttl))
+
+	return count, nil
+}
+
 func (a *memoryAdapter) Delete(ctx context.Context, key string) error {
 	a.getCache().Delete(key)
+	a.tags.removeKey(key)
+	return nil
+}
+
+func (a *memoryAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	a.getCache().DeletePrefix(prefix)
+	a.tags.removePrefix(prefix)
 	return nil
 }
 
@@ -79,5 +183,19 @@ func (a memoryAdapter) Unlock(ctx context.Context, key string) error {
 }
 
 func (a memoryAdapter) ObtainLock(ctx context.Context, key string) (adapter.Lock, error) {
-	return a.locker.Obtain(ctx, key)
+	lock, err := a.locker.Obtain(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return mutex.WithToken(lock, a.tokens.Add(1)), nil
+}
+
+// Zero TTL means the item does not expire.
+func getTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return noExpiry
+	}
+
+	return ttl
 }
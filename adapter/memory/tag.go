@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval in which keys which have expired are pruned from every tag.
+const tagSweepInterval = 1 * time.Minute
+
+type tagIndex struct {
+	mu sync.Mutex
+
+	// Expiry of each key associated with a tag.
+	tags map[string]map[string]time.Time
+
+	// Tags associated with each key, such that deleted keys are removed from their tags.
+	keys map[string]map[string]struct{}
+
+	sweptAt time.Time
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		tags:    make(map[string]map[string]time.Time),
+		keys:    make(map[string]map[string]struct{}),
+		sweptAt: time.Now(),
+	}
+}
+
+func (i *tagIndex) add(key string, ttl time.Duration, tags []string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	expiry := now.Add(ttl)
+
+	for _, tag := range tags {
+		keys, ok := i.tags[tag]
+		if !ok {
+			keys = make(map[string]time.Time)
+			i.tags[tag] = keys
+		}
+
+		if e, ok := keys[key]; !ok || e.Before(expiry) {
+			keys[key] = expiry
+		}
+
+		keyTags, ok := i.keys[key]
+		if !ok {
+			keyTags = make(map[string]struct{})
+			i.keys[key] = keyTags
+		}
+		keyTags[tag] = struct{}{}
+	}
+
+	// Tags which are no longer added to would otherwise keep their expired keys forever.
+	if now.Sub(i.sweptAt) >= tagSweepInterval {
+		i.sweep(now)
+	}
+}
+
+func (i *tagIndex) remove(tag string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	keys := make([]string, 0, len(i.tags[tag]))
+	for key := range i.tags[tag] {
+		keys = append(keys, key)
+		i.unlink(tag, key)
+	}
+
+	return keys
+}
+
+// Remove the key from every tag associated with it.
+func (i *tagIndex) removeKey(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for tag := range i.keys[key] {
+		i.unlink(tag, key)
+	}
+}
+
+func (i *tagIndex) removePrefix(prefix string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, tags := range i.keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		for tag := range tags {
+			i.unlink(tag, key)
+		}
+	}
+}
+
+func (i *tagIndex) sweep(now time.Time) {
+	for tag, keys := range i.tags {
+		for key, e := range keys {
+			if now.After(e) {
+				i.unlink(tag, key)
+			}
+		}
+	}
+
+	i.sweptAt = now
+}
+
+func (i *tagIndex) unlink(tag string, key string) {
+	if keys, ok := i.tags[tag]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(i.tags, tag)
+		}
+	}
+
+	if tags, ok := i.keys[key]; ok {
+		delete(tags, tag)
+		if len(tags) == 0 {
+			delete(i.keys, key)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagIndexSweep(t *testing.T) {
+	i := newTagIndex()
+
+	i.add("key1", time.Duration(10)*time.Millisecond, []string{"tag1", "tag2"})
+	i.add("key2", time.Duration(1)*time.Hour, []string{"tag2"})
+	time.Sleep(time.Duration(20) * time.Millisecond)
+
+	// Adding to another tag prunes the expired keys of every tag once the sweep is due.
+	i.sweptAt = time.Now().Add(-tagSweepInterval)
+	i.add("key3", time.Duration(1)*time.Hour, []string{"tag3"})
+
+	assert.NotContains(t, i.tags, "tag1")
+	assert.Equal(t, map[string]time.Time{"key2": i.tags["tag2"]["key2"]}, i.tags["tag2"])
+	assert.NotContains(t, i.keys, "key1")
+	assert.Len(t, i.keys, 2)
+}
+
+func TestTagIndexRemoveKey(t *testing.T) {
+	i := newTagIndex()
+
+	i.add("prefix###key1", time.Duration(1)*time.Hour, []string{"tag1", "tag2"})
+	i.add("prefix###key2", time.Duration(1)*time.Hour, []string{"tag2"})
+	i.add("key3", time.Duration(1)*time.Hour, []string{"tag2"})
+
+	i.removeKey("key3")
+	assert.Len(t, i.tags["tag2"], 2)
+	assert.NotContains(t, i.keys, "key3")
+
+	i.removePrefix("prefix###")
+	assert.Empty(t, i.tags)
+	assert.Empty(t, i.keys)
+
+	i.add("key4", time.Duration(1)*time.Hour, []string{"tag1"})
+	assert.Equal(t, []string{"key4"}, i.remove("tag1"))
+	assert.Empty(t, i.tags)
+	assert.Empty(t, i.keys)
+}
@@ -6,12 +6,27 @@ import (
 	"time"
 
 	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/fence"
+	"github.com/ezraisw/wracha/adapter/util/glob"
 	"github.com/ezraisw/wracha/adapter/util/mutex"
 	"github.com/ezraisw/wracha/adapter/util/mutex/redsync"
+	"github.com/ezraisw/wracha/adapter/util/mutex/scripted"
+	"github.com/ezraisw/wracha/adapter/util/script"
 	rsredigo "github.com/go-redsync/redsync/v4/redis/redigo"
 	"github.com/gomodule/redigo/redis"
 )
 
+var (
+	tagScript       = redis.NewScript(1, script.Tag)
+	setFencedScript = redis.NewScript(2, script.SetFenced)
+)
+
+// Number of keys deleted in a single round trip when deleting a tag.
+const tagDeleteBatchSize = 500
+
+// Number of keys hinted to be returned by each SCAN when deleting a prefix.
+const scanCount = 500
+
 type redigoAdapter struct {
 	pool   *redis.Pool
 	locker mutex.Locker
@@ -20,10 +35,16 @@ type redigoAdapter struct {
 	multiMutex *mutex.MultiMutex
 }
 
+const DefaultLockTTL = 8 * time.Second
+
 func NewAdapter(pool *redis.Pool) adapter.Adapter {
+	return NewAdapterWithLockTTL(pool, DefaultLockTTL)
+}
+
+func NewAdapterWithLockTTL(pool *redis.Pool, lockTtl time.Duration) adapter.Adapter {
 	return &redigoAdapter{
 		pool:   pool,
-		locker: redsync.NewLocker(rsredigo.NewPool(pool)),
+		locker: scripted.NewLocker(lockScripts{pool: pool}, lockTtl),
 
 		multiMutex: mutex.NewMultiMutex(redsync.NewMutexFactory(rsredigo.NewPool(pool))),
 	}
@@ -31,7 +52,7 @@ func NewAdapter(pool *redis.Pool) adapter.Adapter {
 
 func (a redigoAdapter) Exists(ctx context.Context, key string) (bool, error) {
 	conn := a.pool.Get()
-	defer a.pool.Close()
+	defer conn.Close()
 
 	count, err := redis.Int64(conn.Do(CommandExists, key))
 	if err != nil {
@@ -43,7 +64,7 @@ func (a redigoAdapter) Exists(ctx context.Context, key string) (bool, error) {
 
 func (a redigoAdapter) Get(ctx context.Context, key string) ([]byte, error) {
 	conn := a.pool.Get()
-	defer a.pool.Close()
+	defer conn.Close()
 
 	data, err := redis.Bytes(conn.Do(CommandGet, key))
 	if err != nil {
@@ -67,20 +88,179 @@ func (a redigoAdapter) Set(ctx context.Context, key string, ttl time.Duration, v
 	}
 
 	conn := a.pool.Get()
-	defer a.pool.Close()
+	defer conn.Close()
 
 	_, err := conn.Do(CommandSet, args...)
 	return err
 }
 
+func (a redigoAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return [][]byte{}, nil
+	}
+
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	// Missing keys are converted to nil.
+	return redis.ByteSlices(conn.Do(CommandMGet, args...))
+}
+
+func (a redigoAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	for _, item := range items {
+		args := []any{
+			item.Key, item.Data,
+		}
+
+		if item.TTL > 0 {
+			args = append(args, formatExpirationArgs(item.TTL)...)
+		}
+
+		if err := conn.Send(CommandSet, args...); err != nil {
+			return err
+		}
+	}
+
+	// Flush and receive all pending replies.
+	replies, err := redis.Values(conn.Do(""))
+	if err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a redigoAdapter) SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error) {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(setFencedScript.DoContext(ctx, conn, key, fence.Key(key), data, ttl.Milliseconds(), token))
+}
+
+func (a redigoAdapter) Tag(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	for _, tag := range tags {
+		if _, err := tagScript.DoContext(ctx, conn, tag, ttl.Milliseconds(), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a redigoAdapter) DeleteTag(ctx context.Context, tag string) error {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	keys, err := redis.Strings(conn.Do(CommandSMembers, tag))
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(keys); start += tagDeleteBatchSize {
+		batch := keys[start:min(start+tagDeleteBatchSize, len(keys))]
+
+		args := make([]any, len(batch))
+		for i, key := range batch {
+			args[i] = key
+		}
+
+		if _, err := conn.Do(CommandUnlink, args...); err != nil {
+			return err
+		}
+
+		// Only remove the deleted keys from the tag, as other keys might have been associated in the meantime.
+		if _, err := conn.Do(CommandSRem, append([]any{tag}, args...)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a redigoAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	if ttl <= 0 {
+		return redis.Int64(conn.Do(CommandIncr, key))
+	}
+
+	if err := conn.Send(CommandMulti); err != nil {
+		return 0, err
+	}
+	if err := conn.Send(CommandIncr, key); err != nil {
+		return 0, err
+	}
+	if err := conn.Send(CommandPExpire, key, ttl.Milliseconds()); err != nil {
+		return 0, err
+	}
+
+	replies, err := redis.Values(conn.Do(CommandExec))
+	if err != nil {
+		return 0, err
+	}
+
+	return redis.Int64(replies[0], nil)
+}
+
 func (a redigoAdapter) Delete(ctx context.Context, key string) error {
 	conn := a.pool.Get()
-	defer a.pool.Close()
+	defer conn.Close()
 
 	_, err := conn.Do(CommandDel, key)
 	return err
 }
 
+func (a redigoAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	match := glob.Escape(prefix) + "*"
+
+	var cursor uint64
+	for {
+		replies, err := redis.Values(conn.Do(CommandScan, cursor, "MATCH", match, "COUNT", scanCount))
+		if err != nil {
+			return err
+		}
+
+		var keys []any
+		if _, err := redis.Scan(replies, &cursor, &keys); err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if _, err := conn.Do(CommandUnlink, keys...); err != nil {
+				return err
+			}
+		}
+
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
 func (a redigoAdapter) Lock(ctx context.Context, key string) error {
 	return a.multiMutex.Lock(ctx, key)
 }
@@ -0,0 +1,72 @@
+package redigo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+const lockKey = "lock###testing###testing-key"
+
+func newTestPool(t *testing.T, mr *miniredis.Miniredis) *redis.Pool {
+	addr := mr.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func TestLockLeaseExpired(t *testing.T) {
+	mr := miniredis.RunT(t)
+	pool := newTestPool(t, mr)
+
+	a := NewAdapterWithLockTTL(pool, time.Second)
+	fa := a.(adapter.FencingAdapter)
+	ctx := context.Background()
+
+	stale, err := a.ObtainLock(ctx, lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The action of the first holder outlives its lease.
+	mr.FastForward(2 * time.Second)
+
+	fresh, err := a.ObtainLock(ctx, lockKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	staleToken := stale.(adapter.FencedLock).Token()
+	freshToken := fresh.(adapter.FencedLock).Token()
+	assert.Greater(t, freshToken, staleToken)
+
+	ok, err := fa.SetFenced(ctx, "testing-key", 0, []byte("fresh"), freshToken)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = fa.SetFenced(ctx, "testing-key", 0, []byte("stale"), staleToken)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	data, err := a.Get(ctx, "testing-key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("fresh"), data)
+
+	// The first holder must not be able to extend or release the lock of the second holder.
+	assert.ErrorIs(t, stale.(adapter.ExtendableLock).Extend(ctx), adapter.ErrFailedExtend)
+	assert.ErrorIs(t, stale.Release(ctx), adapter.ErrFailedUnlock)
+	assert.True(t, mr.Exists(lockKey))
+
+	assert.NoError(t, fresh.(adapter.ExtendableLock).Extend(ctx))
+	assert.NoError(t, fresh.Release(ctx))
+	assert.False(t, mr.Exists(lockKey))
+}
@@ -0,0 +1,171 @@
+package redigo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/bus"
+	"github.com/gomodule/redigo/redis"
+)
+
+const DefaultBusChannel = "wracha:invalidations"
+
+const (
+	// Delay before subscribing again after the connection fails.
+	reconnectDelay = 1 * time.Second
+
+	// Maximum duration to wait for the subscription to be confirmed.
+	subscribeTimeout = 5 * time.Second
+
+	// Interval of pings keeping the subscription alive. A connection without replies for twice as long is considered lost.
+	healthCheckInterval = 30 * time.Second
+)
+
+type redigoBus struct {
+	pool    *redis.Pool
+	channel string
+	codec   *bus.Codec
+}
+
+func NewBus(pool *redis.Pool) adapter.InvalidationBus {
+	return NewBusWithChannel(pool, DefaultBusChannel)
+}
+
+func NewBusWithChannel(pool *redis.Pool, channel string) adapter.InvalidationBus {
+	return &redigoBus{
+		pool:    pool,
+		channel: channel,
+		codec:   bus.NewCodec(),
+	}
+}
+
+func (b redigoBus) Publish(ctx context.Context, invalidation adapter.Invalidation) error {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do(CommandPublish, b.channel, b.codec.Encode(invalidation))
+	return err
+}
+
+func (b redigoBus) Subscribe(ctx context.Context, handler adapter.InvalidationHandler) error {
+	psc, err := b.subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	go b.run(ctx, handler, psc)
+	go b.codec.WatchGaps(ctx, handler)
+
+	return nil
+}
+
+// Receive messages until the context is done, subscribing again whenever the connection fails.
+func (b redigoBus) run(ctx context.Context, handler adapter.InvalidationHandler, psc redis.PubSubConn) {
+	for {
+		b.receive(ctx, handler, psc)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Messages are lost while disconnected.
+		handler.Flush(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+
+			var err error
+			if psc, err = b.subscribe(ctx); err == nil {
+				break
+			}
+		}
+
+		// Subscribed again after reconnecting, flush to cover the messages published in between.
+		handler.Flush(ctx)
+	}
+}
+
+// Subscribe on a new connection, waiting for the subscription to be confirmed.
+func (b redigoBus) subscribe(ctx context.Context) (redis.PubSubConn, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return redis.PubSubConn{}, err
+	}
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(b.channel); err != nil {
+		psc.Close()
+		return redis.PubSubConn{}, err
+	}
+
+	receiveCtx, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	defer cancel()
+
+	for {
+		switch reply := psc.ReceiveContext(receiveCtx).(type) {
+		case error:
+			psc.Close()
+			return redis.PubSubConn{}, reply
+
+		case redis.Subscription:
+			if reply.Kind == "subscribe" {
+				return psc, nil
+			}
+		}
+	}
+}
+
+// Receive messages until the connection fails or the context is done.
+func (b redigoBus) receive(ctx context.Context, handler adapter.InvalidationHandler, psc redis.PubSubConn) {
+	defer psc.Close()
+
+	pingCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go b.ping(pingCtx, psc)
+
+	for {
+		receiveCtx, cancel := context.WithTimeout(ctx, 2*healthCheckInterval)
+		reply := psc.ReceiveContext(receiveCtx)
+		cancel()
+
+		switch reply := reply.(type) {
+		case error:
+			return
+
+		case redis.Message:
+			invalidation, foreign, missed, err := b.codec.Decode(string(reply.Data))
+			if err != nil || !foreign {
+				continue
+			}
+
+			if missed {
+				handler.Flush(ctx)
+				continue
+			}
+
+			handler.Invalidate(ctx, invalidation)
+		}
+	}
+}
+
+func (b redigoBus) ping(ctx context.Context, psc redis.PubSubConn) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Failures surface on receive.
+			psc.Ping("")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package redigo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ignores messages, as only the transport is tested here. Ordering and gaps are tested along with the shared codec.
+type nopHandler struct{}
+
+func (nopHandler) Invalidate(ctx context.Context, invalidation adapter.Invalidation) {}
+
+func (nopHandler) Flush(ctx context.Context) {}
+
+func TestBusSubscribeCancelled(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if !assert.NoError(t, NewBus(newTestPool(t, mr)).Subscribe(ctx, nopHandler{})) {
+		cancel()
+		return
+	}
+	assert.Equal(t, 1, mr.PubSubNumSub(DefaultBusChannel)[DefaultBusChannel])
+
+	cancel()
+
+	// The subscription is closed without waiting for another message.
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(DefaultBusChannel)[DefaultBusChannel] == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestBusSubscribeFailed(t *testing.T) {
+	mr := miniredis.RunT(t)
+	pool := newTestPool(t, mr)
+	mr.Close()
+
+	err := NewBus(pool).Subscribe(context.Background(), nopHandler{})
+	assert.Error(t, err)
+}
@@ -3,6 +3,20 @@ package redigo
 var (
 	CommandExists = "EXISTS"
 	CommandGet    = "GET"
+	CommandMGet   = "MGET"
 	CommandSet    = "SET"
 	CommandDel    = "DEL"
+	CommandIncr   = "INCR"
+
+	CommandPExpire = "PEXPIRE"
+	CommandMulti   = "MULTI"
+	CommandExec    = "EXEC"
+
+	CommandScan   = "SCAN"
+	CommandUnlink = "UNLINK"
+
+	CommandSMembers = "SMEMBERS"
+	CommandSRem     = "SREM"
+
+	CommandPublish = "PUBLISH"
 )
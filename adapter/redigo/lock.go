@@ -0,0 +1,41 @@
+package redigo
+
+import (
+	"context"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter/util/fence"
+	"github.com/ezraisw/wracha/adapter/util/script"
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	obtainFencedLockScript = redis.NewScript(2, script.ObtainFencedLock)
+	releaseLockScript      = redis.NewScript(1, script.ReleaseLock)
+	extendLockScript       = redis.NewScript(1, script.ExtendLock)
+)
+
+type lockScripts struct {
+	pool *redis.Pool
+}
+
+func (s lockScripts) Obtain(ctx context.Context, key string, value string, ttl time.Duration) (int64, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(obtainFencedLockScript.DoContext(ctx, conn, key, fence.CounterKey(key), value, ttl.Milliseconds(), fence.CounterTTL.Milliseconds()))
+}
+
+func (s lockScripts) Release(ctx context.Context, key string, value string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(releaseLockScript.DoContext(ctx, conn, key, value))
+}
+
+func (s lockScripts) Extend(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(extendLockScript.DoContext(ctx, conn, key, value, ttl.Milliseconds()))
+}
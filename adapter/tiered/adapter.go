@@ -0,0 +1,349 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+)
+
+// Maximum TTL of data kept in the local tier.
+const DefaultLocalTTL = 1 * time.Minute
+
+type tieredAdapter struct {
+	local    adapter.Adapter
+	shared   adapter.Adapter
+	localTtl time.Duration
+	bus      adapter.InvalidationBus
+}
+
+// Create an adapter which keeps data of a shared adapter (L2) in front of a local adapter (L1).
+//
+// Reads go through the local tier first, filling it on hits of the shared tier.
+// Writes and deletes go to both tiers, while locks are obtained from the shared tier.
+func NewAdapter(local adapter.Adapter, shared adapter.Adapter) adapter.Adapter {
+	return NewAdapterWithLocalTTL(local, shared, DefaultLocalTTL)
+}
+
+func NewAdapterWithLocalTTL(local adapter.Adapter, shared adapter.Adapter, localTtl time.Duration) adapter.Adapter {
+	return &tieredAdapter{
+		local:    local,
+		shared:   shared,
+		localTtl: localTtl,
+	}
+}
+
+// Create a tiered adapter which publishes its writes and deletes to other processes through the bus,
+// and evicts data of the local tier on their writes and deletes until the context is done.
+//
+// The whole local tier is flushed whenever messages might have been missed, or tags are deleted,
+// which requires the local adapter to implement adapter.PrefixAdapter.
+//
+// Fails if the bus cannot be subscribed to, as the local tier would otherwise never be evicted.
+func NewAdapterWithBus(ctx context.Context, local adapter.Adapter, shared adapter.Adapter, localTtl time.Duration, bus adapter.InvalidationBus) (adapter.Adapter, error) {
+	if err := bus.Subscribe(ctx, &localInvalidator{local: local}); err != nil {
+		return nil, err
+	}
+
+	return &tieredAdapter{
+		local:    local,
+		shared:   shared,
+		localTtl: localTtl,
+		bus:      bus,
+	}, nil
+}
+
+func (a tieredAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	if adapter.IsCounterKey(key) {
+		return a.shared.Exists(ctx, key)
+	}
+
+	exists, err := a.local.Exists(ctx, key)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	return a.shared.Exists(ctx, key)
+}
+
+func (a tieredAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	// Counters are incremented by other processes without going through the bus, so they are never kept locally.
+	if adapter.IsCounterKey(key) {
+		return a.shared.Get(ctx, key)
+	}
+
+	data, err := a.local.Get(ctx, key)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, adapter.ErrNotFound) {
+		return nil, err
+	}
+
+	data, err = a.shared.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The remaining TTL in the shared tier is unknown, so it is bounded by the local TTL instead.
+	if err := a.local.Set(ctx, key, a.localTtl, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (a tieredAdapter) Set(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+	if err := a.shared.Set(ctx, key, ttl, data); err != nil {
+		return err
+	}
+
+	if err := a.local.Set(ctx, key, a.getLocalTTL(ttl), data); err != nil {
+		return err
+	}
+
+	return a.publish(ctx, adapter.InvalidateKey, key)
+}
+
+func (a tieredAdapter) SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error) {
+	fa, ok := a.shared.(adapter.FencingAdapter)
+	if !ok {
+		return true, a.Set(ctx, key, ttl, data)
+	}
+
+	stored, err := fa.SetFenced(ctx, key, ttl, data, token)
+	if err != nil {
+		return false, err
+	}
+
+	if !stored {
+		// The local data might be older than the data written by the newer holder.
+		return false, a.local.Delete(ctx, key)
+	}
+
+	if err := a.local.Set(ctx, key, a.getLocalTTL(ttl), data); err != nil {
+		return true, err
+	}
+
+	return true, a.publish(ctx, adapter.InvalidateKey, key)
+}
+
+func (a tieredAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	datas, err := getMany(ctx, a.local, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	missingKeys := make([]string, 0, len(keys))
+	missingIdxs := make([]int, 0, len(keys))
+	for i := range datas {
+		if adapter.IsCounterKey(keys[i]) {
+			datas[i] = nil
+		}
+
+		if datas[i] == nil {
+			missingKeys = append(missingKeys, keys[i])
+			missingIdxs = append(missingIdxs, i)
+		}
+	}
+
+	if len(missingKeys) == 0 {
+		return datas, nil
+	}
+
+	sharedDatas, err := getMany(ctx, a.shared, missingKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]adapter.Item, 0, len(missingKeys))
+	for j, data := range sharedDatas {
+		if data == nil {
+			continue
+		}
+
+		datas[missingIdxs[j]] = data
+		if adapter.IsCounterKey(missingKeys[j]) {
+			continue
+		}
+
+		items = append(items, adapter.Item{Key: missingKeys[j], TTL: a.localTtl, Data: data})
+	}
+
+	if err := setMany(ctx, a.local, items); err != nil {
+		return nil, err
+	}
+
+	return datas, nil
+}
+
+func (a tieredAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	if err := setMany(ctx, a.shared, items); err != nil {
+		return err
+	}
+
+	localItems := make([]adapter.Item, len(items))
+	for i, item := range items {
+		localItems[i] = adapter.Item{Key: item.Key, TTL: a.getLocalTTL(item.TTL), Data: item.Data}
+	}
+
+	if err := setMany(ctx, a.local, localItems); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := a.publish(ctx, adapter.InvalidateKey, item.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a tieredAdapter) Tag(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	ta, ok := a.shared.(adapter.TagAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	return ta.Tag(ctx, key, ttl, tags)
+}
+
+func (a tieredAdapter) DeleteTag(ctx context.Context, tag string) error {
+	ta, ok := a.shared.(adapter.TagAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	if err := ta.DeleteTag(ctx, tag); err != nil {
+		return err
+	}
+
+	// Keys of the tag are only known by the shared tier, so the whole local tier is flushed instead.
+	if err := flushLocal(ctx, a.local); err != nil {
+		return err
+	}
+
+	return a.publish(ctx, adapter.InvalidateTag, tag)
+}
+
+func (a tieredAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	ca, ok := a.shared.(adapter.CounterAdapter)
+	if !ok {
+		return 0, adapter.ErrNotSupported
+	}
+
+	// Counters are only kept in the shared tier.
+	return ca.Incr(ctx, key, ttl)
+}
+
+func (a tieredAdapter) Delete(ctx context.Context, key string) error {
+	// Delete from the shared tier first, so the local tier is not filled again with the deleted data.
+	if err := a.shared.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	if err := a.local.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	return a.publish(ctx, adapter.InvalidateKey, key)
+}
+
+func (a tieredAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	pa, ok := a.shared.(adapter.PrefixAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	if err := pa.DeletePrefix(ctx, prefix); err != nil {
+		return err
+	}
+
+	if lpa, ok := a.local.(adapter.PrefixAdapter); ok {
+		if err := lpa.DeletePrefix(ctx, prefix); err != nil {
+			return err
+		}
+	}
+
+	return a.publish(ctx, adapter.InvalidatePrefix, prefix)
+}
+
+// Deprecated
+func (a tieredAdapter) Lock(ctx context.Context, key string) error {
+	return a.shared.Lock(ctx, key)
+}
+
+// Deprecated
+func (a tieredAdapter) Unlock(ctx context.Context, key string) error {
+	return a.shared.Unlock(ctx, key)
+}
+
+func (a tieredAdapter) ObtainLock(ctx context.Context, key string) (adapter.Lock, error) {
+	return a.shared.ObtainLock(ctx, key)
+}
+
+func (a tieredAdapter) publish(ctx context.Context, kind adapter.InvalidationKind, value string) error {
+	if a.bus == nil {
+		return nil
+	}
+
+	return a.bus.Publish(ctx, adapter.Invalidation{Kind: kind, Value: value})
+}
+
+func (a tieredAdapter) getLocalTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > a.localTtl {
+		return a.localTtl
+	}
+
+	return ttl
+}
+
+func flushLocal(ctx context.Context, local adapter.Adapter) error {
+	if pa, ok := local.(adapter.PrefixAdapter); ok {
+		return pa.DeletePrefix(ctx, "")
+	}
+
+	return nil
+}
+
+func getMany(ctx context.Context, a adapter.Adapter, keys []string) ([][]byte, error) {
+	if ba, ok := a.(adapter.BatchAdapter); ok {
+		return ba.GetMany(ctx, keys)
+	}
+
+	datas := make([][]byte, len(keys))
+	for i, key := range keys {
+		data, err := a.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, adapter.ErrNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		datas[i] = data
+	}
+
+	return datas, nil
+}
+
+func setMany(ctx context.Context, a adapter.Adapter, items []adapter.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	if ba, ok := a.(adapter.BatchAdapter); ok {
+		return ba.SetMany(ctx, items)
+	}
+
+	for _, item := range items {
+		if err := a.Set(ctx, item.Key, item.TTL, item.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package tiered
+
+import (
+	"context"
+
+	"github.com/ezraisw/wracha/adapter"
+)
+
+// Evicts data of the local tier on invalidations published by other processes.
+type localInvalidator struct {
+	local adapter.Adapter
+}
+
+func (i localInvalidator) Invalidate(ctx context.Context, invalidation adapter.Invalidation) {
+	// Errors are ignored, as there is no caller to return them to.
+	switch invalidation.Kind {
+	case adapter.InvalidateKey:
+		i.local.Delete(ctx, invalidation.Value)
+
+	case adapter.InvalidatePrefix:
+		if pa, ok := i.local.(adapter.PrefixAdapter); ok {
+			pa.DeletePrefix(ctx, invalidation.Value)
+		}
+
+	case adapter.InvalidateTag:
+		flushLocal(ctx, i.local)
+	}
+}
+
+func (i localInvalidator) Flush(ctx context.Context) {
+	flushLocal(ctx, i.local)
+}
@@ -0,0 +1,196 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+)
+
+// Publishers which have not been heard from for this long are forgotten.
+const publisherExpiry = 10 * time.Minute
+
+// Messages published concurrently by a process might arrive out of order,
+// so gaps in its sequence are only reported once they have stayed open for this long.
+const DefaultReorderWindow = 1 * time.Second
+
+// Gaps spanning more messages than this are reported at once instead of being waited for.
+const maxOpenGaps = 1024
+
+var errMalformed = errors.New("wracha: malformed invalidation message")
+
+// Encodes invalidations along with the identity and sequence of their publisher,
+// allowing receivers to detect missed messages.
+type Codec struct {
+	id  string
+	seq atomic.Uint64
+
+	mu            sync.Mutex
+	publishers    map[string]*publisher
+	prunedAt      time.Time
+	reorderWindow time.Duration
+}
+
+type publisher struct {
+	// Highest sequence received.
+	seq uint64
+
+	// Sequences below the highest which have not been received yet, along with the time their gap was noticed.
+	gaps map[uint64]time.Time
+
+	lastSeen time.Time
+}
+
+func NewCodec() *Codec {
+	b := make([]byte, 8)
+	// Ignore errors, as the id only has to differ between processes.
+	rand.Read(b)
+
+	return &Codec{
+		id:            hex.EncodeToString(b),
+		publishers:    make(map[string]*publisher),
+		prunedAt:      time.Now(),
+		reorderWindow: DefaultReorderWindow,
+	}
+}
+
+func (c *Codec) Encode(invalidation adapter.Invalidation) string {
+	return c.id + " " +
+		strconv.FormatUint(c.seq.Add(1), 10) + " " +
+		strconv.Itoa(int(invalidation.Kind)) + " " +
+		invalidation.Value
+}
+
+// Decode the message. Returns whether the message is published by another process,
+// and whether messages of its publisher have been missed, having stayed missing for longer than the reorder window.
+func (c *Codec) Decode(payload string) (adapter.Invalidation, bool, bool, error) {
+	parts := strings.SplitN(payload, " ", 4)
+	if len(parts) != 4 {
+		return adapter.Invalidation{}, false, false, errMalformed
+	}
+
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return adapter.Invalidation{}, false, false, errMalformed
+	}
+
+	kind, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return adapter.Invalidation{}, false, false, errMalformed
+	}
+
+	invalidation := adapter.Invalidation{
+		Kind:  adapter.InvalidationKind(kind),
+		Value: parts[3],
+	}
+
+	if parts[0] == c.id {
+		return invalidation, false, false, nil
+	}
+
+	return invalidation, true, c.track(parts[0], seq), nil
+}
+
+// Whether messages of any publisher have been missed, having stayed missing for longer than the reorder window.
+//
+// Gaps are otherwise only noticed on the next message of their publisher, which might never come.
+func (c *Codec) Overdue() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	overdue := false
+	for _, p := range c.publishers {
+		if c.expireGaps(p, now) {
+			overdue = true
+		}
+	}
+	return overdue
+}
+
+// Flush the handler whenever messages have been missed, checking every reorder window until the context is done.
+func (c *Codec) WatchGaps(ctx context.Context, handler adapter.InvalidationHandler) {
+	ticker := time.NewTicker(c.reorderWindow)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.Overdue() {
+				handler.Flush(ctx)
+			}
+		}
+	}
+}
+
+// Record the sequence of the publisher. Returns whether messages of the publisher have been missed.
+func (c *Codec) track(id string, seq uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.prune(now)
+
+	p, ok := c.publishers[id]
+	if !ok {
+		// Nothing is known of messages before the first one.
+		c.publishers[id] = &publisher{seq: seq, gaps: make(map[uint64]time.Time), lastSeen: now}
+		return false
+	}
+	p.lastSeen = now
+
+	missed := false
+	switch {
+	case seq > p.seq+maxOpenGaps:
+		clear(p.gaps)
+		p.seq = seq
+		missed = true
+
+	case seq > p.seq:
+		for s := p.seq + 1; s < seq; s++ {
+			p.gaps[s] = now
+		}
+		p.seq = seq
+
+	default:
+		// Arrived after a later message.
+		delete(p.gaps, seq)
+	}
+
+	return c.expireGaps(p, now) || missed
+}
+
+// Forget gaps which have stayed open for longer than the reorder window. Returns whether there are any.
+func (c *Codec) expireGaps(p *publisher, now time.Time) bool {
+	expired := false
+	for s, noticedAt := range p.gaps {
+		if now.Sub(noticedAt) >= c.reorderWindow {
+			delete(p.gaps, s)
+			expired = true
+		}
+	}
+	return expired
+}
+
+func (c *Codec) prune(now time.Time) {
+	if now.Sub(c.prunedAt) < publisherExpiry {
+		return
+	}
+
+	for id, p := range c.publishers {
+		if now.Sub(p.lastSeen) > publisherExpiry {
+			delete(c.publishers, id)
+		}
+	}
+	c.prunedAt = now
+}
@@ -0,0 +1,132 @@
+package bus
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentPublishers(t *testing.T) {
+	publisher := NewCodec()
+	receiver := NewCodec()
+
+	// Messages are encoded concurrently, and arrive in the order they happen to be sent.
+	var mu sync.Mutex
+	payloads := make([]string, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				payload := publisher.Encode(adapter.Invalidation{Kind: adapter.InvalidateKey, Value: strconv.Itoa(i)})
+
+				mu.Lock()
+				payloads = append(payloads, payload)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Make sure at least some messages are out of order.
+	payloads[1], payloads[2] = payloads[2], payloads[1]
+
+	for _, payload := range payloads {
+		_, foreign, missed, err := receiver.Decode(payload)
+		assert.NoError(t, err)
+		assert.True(t, foreign)
+		assert.False(t, missed)
+	}
+	assert.False(t, receiver.Overdue())
+}
+
+func TestReorderedWithinWindow(t *testing.T) {
+	publisher := NewCodec()
+	receiver := NewCodec()
+	receiver.reorderWindow = 50 * time.Millisecond
+
+	first := publisher.Encode(adapter.Invalidation{Value: "1"})
+	second := publisher.Encode(adapter.Invalidation{Value: "2"})
+	third := publisher.Encode(adapter.Invalidation{Value: "3"})
+
+	assertDecoded(t, receiver, first, false)
+	assertDecoded(t, receiver, third, false)
+	assertDecoded(t, receiver, second, false)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, receiver.Overdue())
+}
+
+func TestMissed(t *testing.T) {
+	publisher := NewCodec()
+	receiver := NewCodec()
+	receiver.reorderWindow = 50 * time.Millisecond
+
+	first := publisher.Encode(adapter.Invalidation{Value: "1"})
+	publisher.Encode(adapter.Invalidation{Value: "2"})
+	third := publisher.Encode(adapter.Invalidation{Value: "3"})
+	fourth := publisher.Encode(adapter.Invalidation{Value: "4"})
+
+	assertDecoded(t, receiver, first, false)
+	assertDecoded(t, receiver, third, false)
+	assert.False(t, receiver.Overdue())
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Reported once, either on the next message or by the periodic check.
+	assertDecoded(t, receiver, fourth, true)
+	assert.False(t, receiver.Overdue())
+}
+
+func TestMissedWithoutNextMessage(t *testing.T) {
+	publisher := NewCodec()
+	receiver := NewCodec()
+	receiver.reorderWindow = 50 * time.Millisecond
+
+	first := publisher.Encode(adapter.Invalidation{Value: "1"})
+	publisher.Encode(adapter.Invalidation{Value: "2"})
+	third := publisher.Encode(adapter.Invalidation{Value: "3"})
+
+	assertDecoded(t, receiver, first, false)
+	assertDecoded(t, receiver, third, false)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, receiver.Overdue())
+	assert.False(t, receiver.Overdue())
+}
+
+func TestMissedTooMany(t *testing.T) {
+	publisher := NewCodec()
+	receiver := NewCodec()
+
+	assertDecoded(t, receiver, publisher.Encode(adapter.Invalidation{Value: "1"}), false)
+	for i := 0; i < maxOpenGaps+1; i++ {
+		publisher.Encode(adapter.Invalidation{})
+	}
+	assertDecoded(t, receiver, publisher.Encode(adapter.Invalidation{Value: "2"}), true)
+}
+
+func TestOwnMessages(t *testing.T) {
+	c := NewCodec()
+
+	invalidation, foreign, missed, err := c.Decode(c.Encode(adapter.Invalidation{Kind: adapter.InvalidatePrefix, Value: "some value"}))
+	assert.NoError(t, err)
+	assert.False(t, foreign)
+	assert.False(t, missed)
+	assert.Equal(t, adapter.Invalidation{Kind: adapter.InvalidatePrefix, Value: "some value"}, invalidation)
+}
+
+func assertDecoded(t *testing.T, c *Codec, payload string, expectedMissed bool) {
+	t.Helper()
+
+	_, foreign, missed, err := c.Decode(payload)
+	assert.NoError(t, err)
+	assert.True(t, foreign)
+	assert.Equal(t, expectedMissed, missed)
+}
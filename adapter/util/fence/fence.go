@@ -0,0 +1,41 @@
+package fence
+
+import (
+	"strings"
+	"time"
+)
+
+// TTL of the counter from which the fencing tokens of a lock are issued, renewed on every issue.
+const CounterTTL = 24 * time.Hour
+
+// Key storing the token of the last fenced write of the given key.
+//
+// The key is kept in the same Redis Cluster slot as the given key, so both can be written by a single script.
+// Keys with braces but without a valid hash tag are not supported on Redis Cluster.
+func Key(key string) string {
+	return withHashTag("fence###", key)
+}
+
+// Key of the counter from which the fencing tokens of the given lock are issued.
+//
+// The key is kept in the same Redis Cluster slot as the lock, so the lock can be obtained along with a token by a single script.
+func CounterKey(lockKey string) string {
+	return withHashTag("fence###counter###", lockKey)
+}
+
+func withHashTag(prefix string, key string) string {
+	if hasHashTag(key) || strings.ContainsAny(key, "{}") {
+		return prefix + key
+	}
+
+	return prefix + "{" + key + "}"
+}
+
+func hasHashTag(key string) bool {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return false
+	}
+
+	return strings.IndexByte(key[start+1:], '}') > 0
+}
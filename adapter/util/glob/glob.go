@@ -0,0 +1,16 @@
+package glob
+
+import "strings"
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`*`, `\*`,
+	`?`, `\?`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+
+// Escape special characters of glob-style patterns used by Redis, such that the string is matched literally.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,30 @@
+package mutex
+
+import "github.com/ezraisw/wracha/adapter"
+
+type fencedLock struct {
+	Lock
+	token int64
+}
+
+type fencedExtendableLock struct {
+	adapter.ExtendableLock
+	token int64
+}
+
+// Attach a fencing token to the lock, retaining its ability to be extended.
+func WithToken(lock Lock, token int64) Lock {
+	if el, ok := lock.(adapter.ExtendableLock); ok {
+		return &fencedExtendableLock{ExtendableLock: el, token: token}
+	}
+
+	return &fencedLock{Lock: lock, token: token}
+}
+
+func (l fencedLock) Token() int64 {
+	return l.token
+}
+
+func (l fencedExtendableLock) Token() int64 {
+	return l.token
+}
@@ -22,7 +22,13 @@ func NewMultiMutex(mutexFactory MutexFactory) *MultiMutex {
 }
 
 func (m MultiMutex) Lock(ctx context.Context, key string) error {
-	return m.getMutexForLock(key).Lock(ctx)
+	if err := m.getMutexForLock(key).Lock(ctx); err != nil {
+		// The waiter gave up, so the mutex must no longer be counted for it.
+		m.getMutexForUnlock(key)
+		return err
+	}
+
+	return nil
 }
 
 func (m MultiMutex) Unlock(ctx context.Context, key string) error {
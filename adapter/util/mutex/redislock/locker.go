@@ -2,6 +2,7 @@ package redislock
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/bsm/redislock"
@@ -28,5 +29,32 @@ func (lr redislockLocker) Obtain(ctx context.Context, key string) (mutex.Lock, e
 	if err != nil {
 		return nil, adapter.ErrFailedLock
 	}
-	return lock, nil
+
+	return &redislockLock{
+		lock:    lock,
+		lockTtl: lr.lockTtl,
+	}, nil
+}
+
+type redislockLock struct {
+	lock    *redislock.Lock
+	lockTtl time.Duration
+}
+
+func (l redislockLock) Release(ctx context.Context) error {
+	return l.lock.Release(ctx)
+}
+
+func (l redislockLock) Extend(ctx context.Context) error {
+	if err := l.lock.Refresh(ctx, l.lockTtl, nil); err != nil {
+		if errors.Is(err, redislock.ErrNotObtained) {
+			return adapter.ErrFailedExtend
+		}
+		return err
+	}
+	return nil
+}
+
+func (l redislockLock) TTL() time.Duration {
+	return l.lockTtl
 }
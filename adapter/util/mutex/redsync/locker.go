@@ -2,6 +2,7 @@ package redsync
 
 import (
 	"context"
+	"time"
 
 	"github.com/ezraisw/wracha/adapter"
 	"github.com/ezraisw/wracha/adapter/util/mutex"
@@ -9,28 +10,41 @@ import (
 	"github.com/go-redsync/redsync/v4/redis"
 )
 
+// Same as the default expiry of redsync.
+const DefaultLockTTL = 8 * time.Second
+
 type redsyncLocker struct {
-	rs *redsync.Redsync
+	rs      *redsync.Redsync
+	lockTtl time.Duration
 }
 
 func NewLocker(pools ...redis.Pool) mutex.Locker {
+	return NewLockerWithLockTTL(DefaultLockTTL, pools...)
+}
+
+func NewLockerWithLockTTL(lockTtl time.Duration, pools ...redis.Pool) mutex.Locker {
 	return &redsyncLocker{
-		rs: redsync.New(pools...),
+		rs:      redsync.New(pools...),
+		lockTtl: lockTtl,
 	}
 }
 
 func (lr redsyncLocker) Obtain(ctx context.Context, key string) (mutex.Lock, error) {
-	mutex := lr.rs.NewMutex(key)
+	mutex := lr.rs.NewMutex(key, redsync.WithExpiry(lr.lockTtl))
 
 	if err := mutex.LockContext(ctx); err != nil {
 		return nil, adapter.ErrFailedLock
 	}
 
-	return &redsyncLock{mutex: mutex}, nil
+	return &redsyncLock{
+		mutex:   mutex,
+		lockTtl: lr.lockTtl,
+	}, nil
 }
 
 type redsyncLock struct {
-	mutex *redsync.Mutex
+	mutex   *redsync.Mutex
+	lockTtl time.Duration
 }
 
 func (l redsyncLock) Release(ctx context.Context) error {
@@ -40,3 +54,15 @@ func (l redsyncLock) Release(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (l redsyncLock) Extend(ctx context.Context) error {
+	ok, err := l.mutex.ExtendContext(ctx)
+	if err != nil || !ok {
+		return adapter.ErrFailedExtend
+	}
+	return nil
+}
+
+func (l redsyncLock) TTL() time.Duration {
+	return l.lockTtl
+}
@@ -0,0 +1,131 @@
+package scripted
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/mutex"
+)
+
+const (
+	minRetryBackoff = 16 * time.Millisecond
+	maxRetryBackoff = 4096 * time.Millisecond
+	maxRetries      = 32
+)
+
+// Scripts run against the server holding the locks.
+type Scripts interface {
+	// Obtain the lock with the given value, returning the fencing token issued by the same script.
+	// Returns zero if the lock is held.
+	Obtain(ctx context.Context, key string, value string, ttl time.Duration) (int64, error)
+
+	// Release the lock if it is still held with the given value.
+	Release(ctx context.Context, key string, value string) (bool, error)
+
+	// Extend the lock if it is still held with the given value.
+	Extend(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+}
+
+type scriptedLocker struct {
+	scripts Scripts
+	lockTtl time.Duration
+}
+
+// Create a locker issuing a fencing token atomically with each obtained lock.
+func NewLocker(scripts Scripts, lockTtl time.Duration) mutex.Locker {
+	return &scriptedLocker{
+		scripts: scripts,
+		lockTtl: lockTtl,
+	}
+}
+
+func (lr scriptedLocker) Obtain(ctx context.Context, key string) (mutex.Lock, error) {
+	value, err := randomValue()
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := minRetryBackoff
+	for retries := 0; ; retries++ {
+		token, err := lr.scripts.Obtain(ctx, key, value, lr.lockTtl)
+		if err != nil {
+			return nil, err
+		}
+
+		if token != 0 {
+			return &scriptedLock{
+				scripts: lr.scripts,
+				key:     key,
+				value:   value,
+				token:   token,
+				lockTtl: lr.lockTtl,
+			}, nil
+		}
+
+		if retries >= maxRetries {
+			return nil, adapter.ErrFailedLock
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, adapter.ErrFailedLock
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+type scriptedLock struct {
+	scripts Scripts
+	key     string
+	value   string
+	token   int64
+	lockTtl time.Duration
+}
+
+func (l scriptedLock) Release(ctx context.Context) error {
+	ok, err := l.scripts.Release(ctx, l.key, l.value)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.ErrFailedUnlock
+	}
+	return nil
+}
+
+func (l scriptedLock) Extend(ctx context.Context) error {
+	ok, err := l.scripts.Extend(ctx, l.key, l.value, l.lockTtl)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.ErrFailedExtend
+	}
+	return nil
+}
+
+func (l scriptedLock) TTL() time.Duration {
+	return l.lockTtl
+}
+
+func (l scriptedLock) Token() int64 {
+	return l.token
+}
+
+func randomValue() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
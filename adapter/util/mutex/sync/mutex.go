@@ -2,11 +2,13 @@ package sync
 
 import (
 	"context"
-	"sync"
+	"errors"
 
 	"github.com/ezraisw/wracha/adapter/util/mutex"
 )
 
+var errNotLocked = errors.New("unlock of unlocked mutex")
+
 type syncMutexFactory struct {
 }
 
@@ -16,20 +18,29 @@ func NewMutexFactory() mutex.MutexFactory {
 
 func (m syncMutexFactory) Make(key string) mutex.Mutex {
 	return &syncMutex{
-		mutex: &sync.Mutex{},
+		sem: make(chan struct{}, 1),
 	}
 }
 
+// A mutex backed by a single-slot channel, such that waiting for it can be abandoned once the context is done.
 type syncMutex struct {
-	mutex *sync.Mutex
+	sem chan struct{}
 }
 
 func (m syncMutex) Lock(ctx context.Context) error {
-	m.mutex.Lock()
-	return nil
+	select {
+	case m.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (m syncMutex) Unlock(ctx context.Context) error {
-	m.mutex.Unlock()
-	return nil
+	select {
+	case <-m.sem:
+		return nil
+	default:
+		return errNotLocked
+	}
 }
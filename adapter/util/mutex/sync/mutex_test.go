@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/adapter/util/mutex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockCancelled(t *testing.T) {
+	m := NewMutexFactory().Make("key")
+	assert.NoError(t, m.Lock(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	// The waiter gives up once its context is done.
+	assert.ErrorIs(t, m.Lock(ctx), context.Canceled)
+
+	assert.NoError(t, m.Unlock(context.Background()))
+	assert.NoError(t, m.Lock(context.Background()))
+	assert.NoError(t, m.Unlock(context.Background()))
+	assert.Error(t, m.Unlock(context.Background()))
+}
+
+func TestMultiMutexCancelled(t *testing.T) {
+	mm := mutex.NewMultiMutex(NewMutexFactory())
+	assert.NoError(t, mm.Lock(context.Background(), "key"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, mm.Lock(ctx, "key"), context.DeadlineExceeded)
+
+	assert.NoError(t, mm.Unlock(context.Background(), "key"))
+
+	// The mutex is no longer counted for the waiter which gave up, so it is gone along with the holder.
+	assert.Panics(t, func() { mm.Unlock(context.Background(), "key") })
+
+	assert.NoError(t, mm.Lock(context.Background(), "key"))
+	assert.NoError(t, mm.Unlock(context.Background(), "key"))
+}
+
+func TestLockerCancelled(t *testing.T) {
+	locker := NewLocker()
+
+	lock, err := locker.Obtain(context.Background(), "key")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = locker.Obtain(ctx, "key")
+	assert.ErrorIs(t, err, adapter.ErrFailedLock)
+
+	obtained := make(chan mutex.Lock)
+	go func() {
+		lock, err := locker.Obtain(context.Background(), "key")
+		assert.NoError(t, err)
+		obtained <- lock
+	}()
+
+	assert.NoError(t, lock.Release(context.Background()))
+
+	select {
+	case lock := <-obtained:
+		assert.NoError(t, lock.Release(context.Background()))
+	case <-time.After(time.Second):
+		t.Fatal("lock was not handed over")
+	}
+}
@@ -0,0 +1,76 @@
+// Lua scripts shared by the Redis adapters.
+package script
+
+// Add the key to the tag set.
+// Only extend the expiry of the tag set, since it is shared by keys of different TTLs.
+//
+// KEYS[1]: tag, ARGV[1]: TTL in milliseconds, ARGV[2]: key.
+const Tag = `
+redis.call("SADD", KEYS[1], ARGV[2])
+local ttl = tonumber(ARGV[1])
+if ttl > 0 and redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`
+
+// Only write if the token is not older than the token of the last write.
+//
+// KEYS[1]: key, KEYS[2]: fence key, ARGV[1]: data, ARGV[2]: TTL in milliseconds, ARGV[3]: token.
+const SetFenced = `
+local last = tonumber(redis.call("GET", KEYS[2]) or "0")
+if tonumber(ARGV[3]) < last then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	redis.call("SET", KEYS[2], ARGV[3], "PX", ARGV[2])
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+	redis.call("SET", KEYS[2], ARGV[3])
+end
+return 1
+`
+
+// Obtain the lock and issue a fencing token within the same script, such that holders taking over the lock
+// always receive a greater token. Returns zero if the lock is held.
+//
+// A missing counter starts from the server time in microseconds, staying greater than the tokens issued
+// before it expired.
+//
+// KEYS[1]: lock key, KEYS[2]: counter key, ARGV[1]: lock value, ARGV[2]: lock TTL in milliseconds,
+// ARGV[3]: counter TTL in milliseconds.
+const ObtainFencedLock = `
+if not redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 0
+end
+if redis.call("EXISTS", KEYS[2]) == 0 then
+	local time = redis.call("TIME")
+	redis.call("SET", KEYS[2], time[1] .. string.format("%06d", tonumber(time[2])))
+end
+local token = redis.call("INCR", KEYS[2])
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+return token
+`
+
+// Release the lock if it is still held with the given value.
+//
+// KEYS[1]: lock key, ARGV[1]: lock value.
+const ReleaseLock = `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+return 1
+`
+
+// Extend the lock if it is still held with the given value.
+//
+// KEYS[1]: lock key, ARGV[1]: lock value, ARGV[2]: lock TTL in milliseconds.
+const ExtendLock = `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1
+`
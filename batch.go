@@ -0,0 +1,189 @@
+package wracha
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+)
+
+func (a defaultActor[T]) DoMany(ctx context.Context, keyables []Keyable, action ManyActionFunc[T]) (map[string]T, error) {
+	ids := make([]string, 0, len(keyables))
+	uniqueKeyables := make([]Keyable, 0, len(keyables))
+	seen := make(map[string]struct{}, len(keyables))
+	for _, keyable := range keyables {
+		id, err := keyable.Key()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		ids = append(ids, id)
+		uniqueKeyables = append(uniqueKeyables, keyable)
+	}
+
+	prefix, err := a.getKeyPrefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = prefix + id
+	}
+
+	datas, err := a.getMany(ctx, keys)
+	if err != nil {
+		// Similar to the default pre-action error handler, proceed as if none of the values are in cache.
+		a.o.Logger.Error(newPreActionError("get", "error while getting values", err))
+		datas = make([][]byte, len(keys))
+	}
+
+	values := make(map[string]T, len(ids))
+
+	now := time.Now()
+	missing := make([]Keyable, 0, len(ids))
+	missingIdxs := make([]int, 0, len(ids))
+	for i, data := range datas {
+		if data != nil {
+			var e entry[T]
+			if err := a.o.Codec.Unmarshal(data, &e); err == nil && e.isFresh(now) {
+				// Cached errors are omitted from the results.
+				if e.Err == nil {
+					values[ids[i]] = e.Value
+				}
+				continue
+			}
+		}
+
+		missing = append(missing, uniqueKeyables[i])
+		missingIdxs = append(missingIdxs, i)
+	}
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	results, delta, err := a.performMany(ctx, missing, action)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]adapter.Item, 0, len(results))
+	itemTags := make([][]string, 0, len(results))
+	itemTtls := make([]time.Duration, 0, len(results))
+	for _, i := range missingIdxs {
+		result, ok := results[ids[i]]
+		if !ok {
+			continue
+		}
+
+		values[ids[i]] = result.Value
+
+		if !result.Cache {
+			continue
+		}
+
+		ttl, ok := a.getTTL(result)
+		if !ok {
+			continue
+		}
+
+		e := newEntry(result.Value, ttl, delta)
+
+		data, err := a.o.Codec.Marshal(&e)
+		if err != nil {
+			a.o.Logger.Error("error while encoding value", err)
+			continue
+		}
+
+		items = append(items, adapter.Item{
+			Key:  keys[i],
+			TTL:  a.getStorageTTL(ttl),
+			Data: data,
+		})
+		itemTags = append(itemTags, result.Tags)
+		itemTtls = append(itemTtls, ttl)
+	}
+
+	// Similar to the default post-action error handler, ignore the errors and return the values.
+	if err := a.setMany(ctx, items); err != nil {
+		a.o.Logger.Error("error while storing values", err)
+		return values, nil
+	}
+
+	for i, item := range items {
+		if err := a.tagKey(ctx, item.Key, itemTtls[i], itemTags[i]); err != nil {
+			a.o.Logger.Error("error while tagging value", err)
+		}
+	}
+
+	return values, nil
+}
+
+func (a defaultActor[T]) getMany(ctx context.Context, keys []string) ([][]byte, error) {
+	if ba, ok := a.o.Adapter.(adapter.BatchAdapter); ok {
+		return ba.GetMany(ctx, keys)
+	}
+
+	datas := make([][]byte, len(keys))
+	for i, key := range keys {
+		data, err := a.o.Adapter.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, adapter.ErrNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		datas[i] = data
+	}
+
+	return datas, nil
+}
+
+func (a defaultActor[T]) setMany(ctx context.Context, items []adapter.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	if ba, ok := a.o.Adapter.(adapter.BatchAdapter); ok {
+		return ba.SetMany(ctx, items)
+	}
+
+	for _, item := range items {
+		if err := a.o.Adapter.Set(ctx, item.Key, item.TTL, item.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a defaultActor[T]) performMany(ctx context.Context, missing []Keyable, action ManyActionFunc[T]) (results map[string]ActionResult[T], delta time.Duration, err error) {
+	a.o.Logger.Debug("perform action", len(missing), "keys")
+
+	start := time.Now()
+	defer func() {
+		delta = time.Since(start)
+	}()
+
+	// Recovered panics are returned as is, since the action error handler only concerns single keys.
+	if a.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				results, err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+
+	results, err = action(ctx, missing)
+	return results, 0, err
+}
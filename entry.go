@@ -0,0 +1,91 @@
+package wracha
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Version of the envelope, telling entries apart from data stored in other formats, such as plain values stored by earlier versions.
+const entryVersion = 1
+
+type (
+	// The envelope of a value stored in cache.
+	entry[T any] struct {
+		Version int
+
+		Value T
+
+		// Unix time in nanoseconds after which the value is considered stale. Zero if the value never expires.
+		ExpiresAt int64
+
+		// Duration in nanoseconds the value is fresh for. Zero if the value never expires.
+		TTL int64
+
+		// Duration in nanoseconds the action took to compute the value.
+		Delta int64
+
+		// The cached error returned by the action, in place of the value.
+		Err *entryError
+	}
+
+	entryError struct {
+		Message string
+
+		// Message of the matching cacheable error, used to rebuild the error.
+		Target string
+	}
+)
+
+func newEntry[T any](value T, ttl time.Duration, delta time.Duration) entry[T] {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	return entry[T]{
+		Version:   entryVersion,
+		Value:     value,
+		ExpiresAt: expiresAt,
+		TTL:       int64(max(ttl, 0)),
+		Delta:     int64(delta),
+	}
+}
+
+func newErrorEntry[T any](err error, target error, ttl time.Duration, delta time.Duration) entry[T] {
+	e := newEntry(zeroOf[T](), ttl, delta)
+	e.Err = &entryError{
+		Message: err.Error(),
+	}
+	if target != nil {
+		e.Err.Target = target.Error()
+	}
+	return e
+}
+
+func (e entry[T]) isFresh(now time.Time) bool {
+	return e.ExpiresAt == 0 || now.UnixNano() < e.ExpiresAt
+}
+
+// Whether the entry is stale but still within the given stale period.
+func (e entry[T]) isStale(now time.Time, staleTtl time.Duration) bool {
+	return !e.isFresh(now) && now.UnixNano() < e.ExpiresAt+int64(staleTtl)
+}
+
+// Whether the entry holds a value which can still be used in place of an error within the given grace period.
+func (e entry[T]) isGraceful(now time.Time, staleTtl time.Duration, grace time.Duration) bool {
+	return e.Err == nil && (e.isFresh(now) || now.UnixNano() < e.ExpiresAt+int64(staleTtl)+int64(grace))
+}
+
+// Whether the entry should be recomputed ahead of its expiry (XFetch).
+//
+// The probability grows as the expiry gets closer and as the value gets more expensive to compute.
+func (e entry[T]) shouldRecomputeEarly(now time.Time, beta float64) bool {
+	if beta <= 0 || e.Delta <= 0 || e.ExpiresAt == 0 {
+		return false
+	}
+
+	// Use (0, 1] to avoid taking the logarithm of zero.
+	gap := -float64(e.Delta) * beta * math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+gap >= float64(e.ExpiresAt)
+}
@@ -1,6 +1,14 @@
 package wracha
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrGenerationsDisabled = errors.New("wracha: generations are disabled")
+	ErrLockTimeout         = errors.New("wracha: timed out while waiting for lock")
+)
 
 type (
 	baseError struct {
@@ -17,6 +25,23 @@ type (
 		baseError
 		result ActionResult[T]
 	}
+
+	// Returned along with a stale value, in place of the error which prevented the value from being recomputed.
+	StaleError struct {
+		Err error
+	}
+
+	// Returned in place of a panic recovered from an action, along with the stack of the panicking goroutine.
+	PanicError struct {
+		Value any
+		Stack []byte
+	}
+
+	// An error rebuilt from cache, returned in place of a cacheable error previously returned by an action.
+	CachedError struct {
+		Message string
+		target  error
+	}
 )
 
 func newPreActionError(category string, message string, previousErr error) *preActionError {
@@ -47,3 +72,30 @@ func (e baseError) Error() string {
 func (a baseError) Unwrap() error {
 	return a.previousErr
 }
+
+func (e CachedError) Error() string {
+	return e.Message
+}
+
+// Returns the matching cacheable error set in the actor, if any.
+func (e CachedError) Unwrap() error {
+	return e.target
+}
+
+func (e StaleError) Error() string {
+	return fmt.Sprintf("stale value returned (%s)", e.Err.Error())
+}
+
+func (e StaleError) Unwrap() error {
+	return e.Err
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("action panicked (%v)", e.Value)
+}
+
+// Returns the recovered value if it is an error.
+func (e PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
@@ -0,0 +1,169 @@
+package wracha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+type (
+	// Collapses concurrent loads of the same key within a process into a single flight.
+	flightGroup[T any] struct {
+		mu      sync.Mutex
+		flights map[string]*flight[T]
+
+		// Called with panics of detached flights whose leader is no longer waiting.
+		onAbandonedPanic func(key string, err *PanicError)
+	}
+
+	flight[T any] struct {
+		done   chan struct{}
+		result flightResult[T]
+
+		// Panic recovered from the flight along with its stack, to be propagated to the leader.
+		panicked *PanicError
+
+		mu        sync.Mutex
+		finished  bool
+		abandoned bool
+	}
+
+	flightResult[T any] struct {
+		value  T
+		cached bool
+		err    error
+	}
+
+	// Panic of a detached flight propagated to the leader, retaining the stack of the goroutine in which it occurred.
+	flightPanic struct {
+		*PanicError
+	}
+)
+
+func newFlightGroup[T any](onAbandonedPanic func(key string, err *PanicError)) *flightGroup[T] {
+	return &flightGroup[T]{
+		flights:          make(map[string]*flight[T]),
+		onAbandonedPanic: onAbandonedPanic,
+	}
+}
+
+// Execute fn as the leader of a flight for the given key, or wait for the result of the flight in progress.
+//
+// If detached, fn is executed in its own goroutine, such that the leader is also able to stop waiting once its context is done.
+// Returns whether the result was shared by another caller.
+func (g *flightGroup[T]) do(ctx context.Context, key string, detached bool, fn func() flightResult[T]) (flightResult[T], bool) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		return f.wait(ctx, true)
+	}
+
+	f := &flight[T]{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	if !detached {
+		g.run(key, f, false, fn)
+	} else {
+		go g.run(key, f, true, fn)
+	}
+
+	return f.wait(ctx, false)
+}
+
+func (g *flightGroup[T]) run(key string, f *flight[T], detached bool, fn func() flightResult[T]) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			f.panicked = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+
+		f.mu.Lock()
+		f.finished = true
+		abandoned := f.abandoned
+		f.mu.Unlock()
+
+		close(f.done)
+
+		if r == nil {
+			return
+		}
+
+		// Still on the goroutine of the leader, whose stack is retained by panicking again right away.
+		if !detached {
+			panic(r)
+		}
+
+		if abandoned && g.onAbandonedPanic != nil {
+			g.onAbandonedPanic(key, f.panicked)
+		}
+	}()
+
+	f.result = fn()
+}
+
+func (f *flight[T]) wait(ctx context.Context, shared bool) (flightResult[T], bool) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		if shared || f.abandon() {
+			return flightResult[T]{err: ctx.Err()}, false
+		}
+
+		// Finished meanwhile, so the leader still takes over a panic of the flight.
+		<-f.done
+	}
+
+	if f.panicked != nil {
+		if !shared {
+			panic(flightPanic{f.panicked})
+		}
+
+		// Let the other callers perform the action on their own.
+		return flightResult[T]{err: context.Canceled}, true
+	}
+
+	return f.result, shared
+}
+
+// Mark the flight as no longer awaited by its leader. Returns false if the flight has already finished.
+func (f *flight[T]) abandon() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.finished {
+		return false
+	}
+
+	f.abandoned = true
+	return true
+}
+
+// Whether the result can be reused by callers other than the leader.
+func (r flightResult[T]) reusable(shareUncached bool) bool {
+	if r.cached {
+		return true
+	}
+
+	// Cancellation of the leader does not concern the other callers.
+	if errors.Is(r.err, context.Canceled) || errors.Is(r.err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// Errors are shared, but values which were not cached might be specific to the leader unless stated otherwise.
+	return r.err != nil || shareUncached
+}
+
+func (p flightPanic) Error() string {
+	return fmt.Sprintf("%s\n\n%s", p.PanicError.Error(), p.Stack)
+}
+
+func (p flightPanic) Unwrap() error {
+	return p.PanicError
+}
@@ -15,10 +15,16 @@ import (
 type (
 	ActionFunc[T any] func(ctx context.Context) (ActionResult[T], error)
 
+	// Action for multiple keys, given only the keys which are missing from cache.
+	// Results must be keyed by the string returned from Keyable.Key.
+	ManyActionFunc[T any] func(ctx context.Context, missing []Keyable) (map[string]ActionResult[T], error)
+
 	PreActionErrorHandlerFunc[T any] func(ctx context.Context, args PreActionErrorHandlerArgs[T]) (T, error)
 
 	PostActionErrorHandlerFunc[T any] func(ctx context.Context, args PostActionErrorHandlerArgs[T]) (T, error)
 
+	ActionErrorHandlerFunc[T any] func(ctx context.Context, args ActionErrorHandlerArgs[T]) (T, error)
+
 	PreActionErrorHandlerArgs[T any] struct {
 		Key         Keyable
 		Action      ActionFunc[T]
@@ -34,6 +40,12 @@ type (
 		Err         error
 	}
 
+	ActionErrorHandlerArgs[T any] struct {
+		Key    Keyable
+		Action ActionFunc[T]
+		Err    *PanicError
+	}
+
 	ActionResult[T any] struct {
 		// Whether to cache the returned values.
 		Cache bool
@@ -43,6 +55,34 @@ type (
 
 		// The values to cache and return.
 		Value T
+
+		// Tags associated with the cached values.
+		// Used for invalidating entries across actors sharing the same adapter through Actor.InvalidateTag.
+		Tags []string
+	}
+
+	// Determines how Actor.Do proceeds when the lock is not obtained within the lock timeout.
+	LockTimeoutPolicy int
+
+	RefreshAheadOptions struct {
+		// Duration before expiry in which hot keys are refreshed. Defaults to a tenth of the TTL of each value.
+		Lead time.Duration
+
+		// Interval between checks of the tracked keys. Keys which fail to refresh are retried after an interval doubling on
+		// each failure, up to the window.
+		Interval time.Duration
+
+		// Minimum number of reads within the window for a key to be considered hot.
+		MinHits int
+
+		// Window in which reads are counted. Keys which are not read within the window are no longer tracked.
+		Window time.Duration
+
+		// Maximum number of tracked keys.
+		MaxKeys int
+
+		// Number of workers performing the refreshes.
+		Workers int
 	}
 
 	ActorOptions struct {
@@ -55,6 +95,64 @@ type (
 		// Set default TTL of cache.
 		SetTTL(ttl time.Duration) Actor[T]
 
+		// Set the duration after TTL in which an expired value is still returned while being refreshed in background.
+		//
+		// The refresh is guarded by the same lock as the action. Set to zero to disable.
+		SetStaleTTL(ttl time.Duration) Actor[T]
+
+		// Set beta of probabilistic early recomputation (XFetch).
+		//
+		// Values are refreshed in background before they expire, with a probability that grows as the expiry
+		// gets closer. Higher beta favors earlier recomputation, 1.0 being a sensible default. Set to zero to disable.
+		SetEarlyRecomputeBeta(beta float64) Actor[T]
+
+		// Set the grace period after expiry (and stale TTL) in which the last known value is kept for when the action fails.
+		//
+		// If the action returns an error within the period, the value is returned along with a StaleError wrapping the error.
+		// Set to zero to disable.
+		SetStaleIfError(grace time.Duration) Actor[T]
+
+		// Set the action of the leader to be detached from the cancellation of its caller, bounded by the given timeout instead.
+		//
+		// Callers stop waiting once their own context is done, while the leader keeps loading the value for the others.
+		// The action is given a context which retains the values of the caller. Set to zero to disable.
+		SetDetachedAction(timeout time.Duration) Actor[T]
+
+		// Start refreshing keys which are read recently and often in background, shortly before they expire.
+		//
+		// The action last given to Actor.Do for each key is kept and performed by a bounded pool of workers,
+		// without the values of the original context. Stop the refreshes with Actor.Close.
+		SetRefreshAhead(options RefreshAheadOptions) Actor[T]
+
+		// Set whether results which are not cached are shared with concurrent callers of the same key within the process.
+		//
+		// By default, each waiting caller performs the action on its own when the result is not cached.
+		SetShareUncachedResults(share bool) Actor[T]
+
+		// Set TTL of cached errors. Set to zero to disable caching errors.
+		SetNegativeTTL(ttl time.Duration) Actor[T]
+
+		// Set errors returned by the action to be cached, matched with errors.Is.
+		//
+		// Subsequent calls will return a CachedError which unwraps to the matching error without performing the action.
+		SetCacheableErrors(targets ...error) Actor[T]
+
+		// Set predicate determining whether an error returned by the action is cached.
+		//
+		// Subsequent calls will return a CachedError with the same message without performing the action.
+		SetCacheableErrorFunc(predicate func(err error) bool) Actor[T]
+
+		// Set whether keys include a generation counter stored in the adapter, allowing Actor.InvalidateAll.
+		//
+		// Each key lookup costs an additional read of the counter. Requires the adapter to implement adapter.CounterAdapter.
+		SetGenerations(enabled bool) Actor[T]
+
+		// Set whether Actor.Invalidate bumps a counter of the key stored in the adapter, such that actions in progress
+		// during the invalidation do not store their results.
+		//
+		// Each action costs two additional reads of the counter. Requires the adapter to implement adapter.CounterAdapter.
+		SetInvalidationEpochs(enabled bool) Actor[T]
+
 		// Set error handler for handling unconventional errors thrown before action (get in cache and lock).
 		//
 		// Value and error returned by the handler will be forwarded as a return value for Actor.Do.
@@ -65,12 +163,70 @@ type (
 		// Value and error returned by the handler will be forwarded as a return value for Actor.Do.
 		SetPostActionErrorHandler(handler PostActionErrorHandlerFunc[T]) Actor[T]
 
+		// Set the maximum duration to wait for the lock, and how to proceed once it is exceeded.
+		//
+		// Set to zero to leave the wait to the adapter.
+		SetLockTimeout(timeout time.Duration, policy LockTimeoutPolicy) Actor[T]
+
+		// Set error handler for handling panics recovered from the action.
+		//
+		// Value and error returned by the handler will be forwarded as a return value for Actor.Do.
+		SetActionErrorHandler(handler ActionErrorHandlerFunc[T]) Actor[T]
+
+		// Set whether panics of the action are recovered and returned as PanicError instead.
+		//
+		// Recovered panics are not cached and are passed to the action error handler.
+		SetRecoverPanics(enabled bool) Actor[T]
+
 		// Invalidate the value of the given key.
 		Invalidate(ctx context.Context, key Keyable) error
 
+		// Invalidate the values of every key starting with the given prefix.
+		//
+		// Only meaningful for keys which are not hashed, such as KeyableStr. Requires the adapter to implement adapter.PrefixAdapter.
+		InvalidatePrefix(ctx context.Context, prefix string) error
+
+		// Invalidate the values associated with the given tag, including those cached by other actors sharing the same adapter.
+		//
+		// Requires the adapter to implement adapter.TagAdapter.
+		InvalidateTag(ctx context.Context, tag string) error
+
+		// Invalidate every value of the actor by bumping its generation. Previous values are left to expire by their TTL.
+		//
+		// Requires generations to be enabled.
+		InvalidateAll(ctx context.Context) error
+
+		// Store the value of the given key directly. If TTL is zero, defaults to the actor settings.
+		Set(ctx context.Context, key Keyable, value T, ttl time.Duration) error
+
+		// Get the value of the given key without ever performing an action.
+		//
+		// Returns whether the value exists in cache. Stale values are returned as well, and cached errors are returned as CachedError.
+		Peek(ctx context.Context, key Keyable) (T, bool, error)
+
+		// Check whether the key exists in cache, including stale values and cached errors.
+		//
+		// Agrees with Actor.Peek, such that values kept past their stale TTL only for Actor.SetStaleIfError are not reported.
+		Has(ctx context.Context, key Keyable) (bool, error)
+
+		// Stop background work of the actor, waiting for refreshes in progress to finish.
+		Close() error
+
 		// Perform an action.
 		// The action will not be executed again if the key exists in cache.
 		Do(ctx context.Context, key Keyable, action ActionFunc[T]) (T, error)
+
+		// Perform an action regardless of the value in cache, and overwrite it with the result.
+		//
+		// The same lock as Actor.Do is used, and the previous value is still returned to other callers until it is overwritten.
+		Refresh(ctx context.Context, key Keyable, action ActionFunc[T]) (T, error)
+
+		// Perform an action for multiple keys at once.
+		// The action is performed once with only the keys which do not exist in cache, and its results are stored in a single batch.
+		//
+		// Values are keyed by the string returned from Keyable.Key. Keys without a value or with a cached error are omitted.
+		// Unlike Actor.Do, no lock is attempted and stale values are recomputed.
+		DoMany(ctx context.Context, keys []Keyable, action ManyActionFunc[T]) (map[string]T, error)
 	}
 
 	Keyable interface {
@@ -81,6 +237,21 @@ type (
 	KeyableStr string
 )
 
+const (
+	// Return ErrLockTimeout.
+	LockTimeoutError LockTimeoutPolicy = iota
+
+	// Perform the action without storing its result.
+	LockTimeoutRunAction
+
+	// Return the value in cache along with a StaleError wrapping ErrLockTimeout, or ErrLockTimeout if there is none.
+	LockTimeoutStale
+
+	// Keep reading the cache for another lock timeout until the value is stored by the holder of the lock.
+	// Otherwise, the lock is attempted once more, returning ErrLockTimeout if it is still held.
+	LockTimeoutPoll
+)
+
 func (m KeyableMap) Key() (string, error) {
 	hash := sha1.New()
 	if err := msgpack.NewEncoder(hash).Encode(m); err != nil {
@@ -3,6 +3,9 @@ package wracha
 import (
 	"context"
 	"errors"
+	"runtime/debug"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ezraisw/wracha/adapter"
@@ -13,13 +16,39 @@ type (
 		o                    ActorOptions
 		name                 string
 		ttl                  time.Duration
+		staleTtl             time.Duration
+		beta                 float64
+		shareUncached        bool
+		negativeTtl          time.Duration
+		cacheableErrs        []error
+		cacheableErrFunc     func(err error) bool
+		generations          bool
+		epochs               bool
+		staleIfErrorTtl      time.Duration
+		actionTimeout        time.Duration
+		recoverPanics        bool
+		lockTimeout          time.Duration
+		lockTimeoutPolicy    LockTimeoutPolicy
+		actionErrHandler     ActionErrorHandlerFunc[T]
 		preActionErrHandler  PreActionErrorHandlerFunc[T]
 		postActionErrHandler PostActionErrorHandlerFunc[T]
+
+		// Keys currently being refreshed in background.
+		revalidating *sync.Map
+
+		// Background refreshes in progress, waited for on close.
+		background *sync.WaitGroup
+
+		flights *flightGroup[T]
+
+		refresher *refresher[T]
 	}
 )
 
 const (
-	TTLDefault = time.Duration(10) * time.Minute
+	TTLDefault              = time.Duration(10) * time.Minute
+	NegativeTTLDefault      = time.Duration(1) * time.Minute
+	LockPollIntervalDefault = time.Duration(50) * time.Millisecond
 )
 
 func NewActor[T any](name string, options ActorOptions) Actor[T] {
@@ -33,12 +62,22 @@ func NewActor[T any](name string, options ActorOptions) Actor[T] {
 		panic("logger not provided")
 	}
 
+	// Panics of detached actions are otherwise lost once the caller has stopped waiting.
+	flights := newFlightGroup[T](func(key string, err *PanicError) {
+		options.Logger.Error("abandoned action panicked", key, err, string(err.Stack))
+	})
+
 	return &defaultActor[T]{
 		o:                    options,
 		name:                 name,
 		ttl:                  TTLDefault,
+		negativeTtl:          NegativeTTLDefault,
+		actionErrHandler:     DefaultActionErrorHandler[T],
 		preActionErrHandler:  DefaultPreActionErrorHandler[T],
 		postActionErrHandler: DefaultPostActionErrorHandler[T],
+		revalidating:         &sync.Map{},
+		background:           &sync.WaitGroup{},
+		flights:              flights,
 	}
 }
 
@@ -50,6 +89,113 @@ func (a *defaultActor[T]) SetTTL(ttl time.Duration) Actor[T] {
 	return a
 }
 
+func (a *defaultActor[T]) SetStaleTTL(ttl time.Duration) Actor[T] {
+	if ttl < 0 {
+		ttl = 0
+	}
+	a.staleTtl = ttl
+	return a
+}
+
+func (a *defaultActor[T]) SetEarlyRecomputeBeta(beta float64) Actor[T] {
+	if beta < 0 {
+		beta = 0
+	}
+	a.beta = beta
+	return a
+}
+
+func (a *defaultActor[T]) SetShareUncachedResults(share bool) Actor[T] {
+	a.shareUncached = share
+	return a
+}
+
+func (a *defaultActor[T]) SetNegativeTTL(ttl time.Duration) Actor[T] {
+	if ttl < 0 {
+		ttl = 0
+	}
+	a.negativeTtl = ttl
+	return a
+}
+
+func (a *defaultActor[T]) SetCacheableErrors(targets ...error) Actor[T] {
+	a.cacheableErrs = targets
+	return a
+}
+
+func (a *defaultActor[T]) SetCacheableErrorFunc(predicate func(err error) bool) Actor[T] {
+	a.cacheableErrFunc = predicate
+	return a
+}
+
+func (a *defaultActor[T]) SetGenerations(enabled bool) Actor[T] {
+	a.generations = enabled
+	return a
+}
+
+func (a *defaultActor[T]) SetInvalidationEpochs(enabled bool) Actor[T] {
+	a.epochs = enabled
+	return a
+}
+
+func (a *defaultActor[T]) SetStaleIfError(grace time.Duration) Actor[T] {
+	if grace < 0 {
+		grace = 0
+	}
+	a.staleIfErrorTtl = grace
+	return a
+}
+
+func (a *defaultActor[T]) SetDetachedAction(timeout time.Duration) Actor[T] {
+	if timeout < 0 {
+		timeout = 0
+	}
+	a.actionTimeout = timeout
+	return a
+}
+
+func (a *defaultActor[T]) SetRefreshAhead(options RefreshAheadOptions) Actor[T] {
+	if a.refresher != nil {
+		a.refresher.stop()
+	}
+
+	a.refresher = newRefresher(a, options)
+	a.refresher.start()
+	return a
+}
+
+func (a *defaultActor[T]) Close() error {
+	if a.refresher != nil {
+		a.refresher.stop()
+	}
+	a.background.Wait()
+
+	return nil
+}
+
+func (a *defaultActor[T]) SetLockTimeout(timeout time.Duration, policy LockTimeoutPolicy) Actor[T] {
+	if timeout < 0 {
+		timeout = 0
+	}
+	a.lockTimeout = timeout
+	a.lockTimeoutPolicy = policy
+	return a
+}
+
+func (a *defaultActor[T]) SetRecoverPanics(enabled bool) Actor[T] {
+	a.recoverPanics = enabled
+	return a
+}
+
+func (a *defaultActor[T]) SetActionErrorHandler(errHandler ActionErrorHandlerFunc[T]) Actor[T] {
+	if errHandler == nil {
+		panic("nil handler")
+	}
+
+	a.actionErrHandler = errHandler
+	return a
+}
+
 func (a *defaultActor[T]) SetPreActionErrorHandler(errHandler PreActionErrorHandlerFunc[T]) Actor[T] {
 	if errHandler == nil {
 		panic("nil handler")
@@ -69,35 +215,181 @@ func (a *defaultActor[T]) SetPostActionErrorHandler(errHandler PostActionErrorHa
 }
 
 func (a defaultActor[T]) Invalidate(ctx context.Context, keyable Keyable) error {
-	key, err := a.getKey(keyable)
+	key, err := a.getKey(ctx, keyable)
 	if err != nil {
 		return err
 	}
 
+	if a.epochs {
+		ca, ok := a.o.Adapter.(adapter.CounterAdapter)
+		if !ok {
+			return adapter.ErrNotSupported
+		}
+
+		// Bump the epoch before deleting, so that actions in progress do not store their results afterwards.
+		if _, err := ca.Incr(ctx, a.getEpochKey(key), a.getStorageTTL(a.ttl)); err != nil {
+			return err
+		}
+	}
+
 	// No need for lock.
 	return a.o.Adapter.Delete(ctx, key)
 }
 
+func (a defaultActor[T]) InvalidatePrefix(ctx context.Context, prefix string) error {
+	pa, ok := a.o.Adapter.(adapter.PrefixAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	keyPrefix, err := a.getKeyPrefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	// No need for lock.
+	return pa.DeletePrefix(ctx, keyPrefix+prefix)
+}
+
+func (a defaultActor[T]) InvalidateAll(ctx context.Context) error {
+	if !a.generations {
+		return ErrGenerationsDisabled
+	}
+
+	ca, ok := a.o.Adapter.(adapter.CounterAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	// Entries of previous generations are left to expire by their TTL.
+	generation, err := ca.Incr(ctx, a.getGenerationKey(), 0)
+	if err != nil {
+		return err
+	}
+
+	a.o.Logger.Debug("generation bumped", a.name, generation)
+	return nil
+}
+
+func (a defaultActor[T]) InvalidateTag(ctx context.Context, tag string) error {
+	ta, ok := a.o.Adapter.(adapter.TagAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	return ta.DeleteTag(ctx, getTagKey(tag))
+}
+
+func (a defaultActor[T]) Set(ctx context.Context, keyable Keyable, value T, ttl time.Duration) error {
+	key, err := a.getKey(ctx, keyable)
+	if err != nil {
+		return err
+	}
+
+	result := ActionResult[T]{
+		Cache: true,
+		TTL:   ttl,
+		Value: value,
+	}
+
+	// No need for lock.
+	return a.storeValue(ctx, key, result, 0, 0)
+}
+
+func (a defaultActor[T]) Peek(ctx context.Context, keyable Keyable) (T, bool, error) {
+	e, ok, err := a.peekEntry(ctx, keyable)
+	if err != nil || !ok {
+		return zeroOf[T](), false, err
+	}
+
+	value, err := a.resolveEntry(e)
+	return value, true, err
+}
+
+func (a defaultActor[T]) Has(ctx context.Context, keyable Keyable) (bool, error) {
+	_, ok, err := a.peekEntry(ctx, keyable)
+	return ok, err
+}
+
+// Get the entry of the given key if it is fresh or stale. Entries kept only for the grace period of errors are omitted.
+func (a defaultActor[T]) peekEntry(ctx context.Context, keyable Keyable) (entry[T], bool, error) {
+	key, err := a.getKey(ctx, keyable)
+	if err != nil {
+		return entry[T]{}, false, err
+	}
+
+	e, err := a.getEntry(ctx, key)
+	if err != nil {
+		if errors.Is(err, adapter.ErrNotFound) {
+			return entry[T]{}, false, nil
+		}
+
+		return entry[T]{}, false, err
+	}
+
+	now := time.Now()
+	if !e.isFresh(now) && !e.isStale(now, a.staleTtl) {
+		return entry[T]{}, false, nil
+	}
+
+	return e, true, nil
+}
+
 func (a defaultActor[T]) Do(ctx context.Context, keyable Keyable, action ActionFunc[T]) (T, error) {
 	value, err := a.handle(ctx, keyable, action)
 	if err != nil {
-		var preErr *preActionError
-		if errors.As(err, &preErr) {
-			return a.handlePreActionError(ctx, keyable, action, preErr)
-		}
+		return a.handleError(ctx, keyable, action, value, err)
+	}
 
-		var postErr *postActionError[T]
-		if errors.As(err, &postErr) {
-			return a.handlePostActionError(ctx, keyable, action, postErr)
-		}
+	return value, nil
+}
 
-		// Error from action.
-		return zeroOf[T](), err
+func (a defaultActor[T]) Refresh(ctx context.Context, keyable Keyable, action ActionFunc[T]) (T, error) {
+	value, err := a.refresh(ctx, keyable, action)
+	if err != nil {
+		return a.handleError(ctx, keyable, action, value, err)
 	}
 
 	return value, nil
 }
 
+func (a defaultActor[T]) handleError(ctx context.Context, keyable Keyable, action ActionFunc[T], value T, err error) (T, error) {
+	// Stale value returned in place of the error.
+	var staleErr *StaleError
+	if errors.As(err, &staleErr) {
+		return value, err
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return a.handleActionError(ctx, keyable, action, panicErr)
+	}
+
+	var preErr *preActionError
+	if errors.As(err, &preErr) {
+		return a.handlePreActionError(ctx, keyable, action, preErr)
+	}
+
+	var postErr *postActionError[T]
+	if errors.As(err, &postErr) {
+		return a.handlePostActionError(ctx, keyable, action, postErr)
+	}
+
+	// Error from action.
+	return zeroOf[T](), err
+}
+
+func (a defaultActor[T]) handleActionError(ctx context.Context, keyable Keyable, action ActionFunc[T], panicErr *PanicError) (T, error) {
+	a.o.Logger.Error(panicErr, string(panicErr.Stack))
+
+	args := ActionErrorHandlerArgs[T]{
+		Key:    keyable,
+		Action: action,
+		Err:    panicErr,
+	}
+	return a.actionErrHandler(ctx, args)
+}
+
 func (a defaultActor[T]) handlePreActionError(ctx context.Context, keyable Keyable, action ActionFunc[T], preErr *preActionError) (T, error) {
 	a.o.Logger.Error(preErr)
 
@@ -124,62 +416,398 @@ func (a defaultActor[T]) handlePostActionError(ctx context.Context, keyable Keya
 }
 
 func (a defaultActor[T]) handle(ctx context.Context, keyable Keyable, action ActionFunc[T]) (T, error) {
-	key, err := a.getKey(keyable)
+	key, err := a.getKey(ctx, keyable)
 	if err != nil {
 		return zeroOf[T](), newPreActionError("key", "error while creating key", err)
 	}
 
-	value, err := a.getValue(ctx, key)
+	if a.refresher != nil {
+		a.refresher.track(key, action)
+	}
+
+	e, err := a.getEntry(ctx, key)
 	if err != nil {
 		// If value is not found, attempt to lazy load the value into cache.
 		// To speed up future requests, only attempt the lock if the value does not exist in cache.
 		if errors.Is(err, adapter.ErrNotFound) {
-			lockKey := "lock###" + key
+			return a.loadShared(ctx, key, action)
+		}
+
+		return zeroOf[T](), newPreActionError("get", "error while getting value", err)
+	}
+
+	now := time.Now()
+
+	// Pre-lock value get.
+	if e.isFresh(now) {
+		// Recompute ahead of expiry so that processes do not all miss at the same moment.
+		if e.shouldRecomputeEarly(now, a.beta) {
+			a.revalidate(ctx, key, e, action)
+		}
+
+		return a.resolveEntry(e)
+	}
+
+	// Serve the stale value while it is being refreshed.
+	if e.isStale(now, a.staleTtl) {
+		a.revalidate(ctx, key, e, action)
+		return a.resolveEntry(e)
+	}
+
+	// The entry outlived its stale period, which can happen when the stale TTL is shortened.
+	return a.loadShared(ctx, key, action)
+}
+
+// Load the value while collapsing concurrent loads of the same key within this process.
+// Only the leader of the flight attempts the lock.
+func (a defaultActor[T]) loadShared(ctx context.Context, key string, action ActionFunc[T]) (T, error) {
+	detached := a.actionTimeout > 0
+
+	result, shared := a.flights.do(ctx, key, detached, func() flightResult[T] {
+		ctx := ctx
+		if detached {
+			// The result is stored for the other callers even if the leader is no longer waiting.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), a.actionTimeout)
+			defer cancel()
+		}
+
+		value, cached, err := a.load(ctx, key, action)
+		return flightResult[T]{value: value, cached: cached, err: err}
+	})
+
+	if shared && !result.reusable(a.shareUncached) {
+		a.o.Logger.Debug("flight result not reusable", key)
+
+		value, _, err := a.load(ctx, key, action)
+		return value, err
+	}
+
+	return result.value, result.err
+}
+
+// Load the value under lock. Returns whether the value is cached.
+func (a defaultActor[T]) load(ctx context.Context, key string, action ActionFunc[T]) (T, bool, error) {
+	lockKey := a.getLockKey(key)
+
+	lock, err := a.obtainLock(ctx, lockKey)
+	if errors.Is(err, ErrLockTimeout) {
+		return a.loadOnLockTimeout(ctx, key, action)
+	}
+	if err != nil {
+		return zeroOf[T](), false, err
+	}
+	defer a.releaseLock(ctx, lockKey, lock)
+
+	return a.loadLocked(ctx, key, lock, action)
+}
+
+// Load the value once the lock is obtained. Returns whether the value is cached.
+func (a defaultActor[T]) loadLocked(ctx context.Context, key string, lock adapter.Lock, action ActionFunc[T]) (T, bool, error) {
+	// Check for a second time.
+	// This is required because one or more processes/threads might have already reached the locking stage.
+	e, err := a.getEntry(ctx, key)
+	if err != nil && !errors.Is(err, adapter.ErrNotFound) {
+		return zeroOf[T](), false, newPreActionError("get", "error while getting value", err)
+	}
+
+	found := err == nil
+
+	// Post-lock value get.
+	if found && e.isFresh(time.Now()) {
+		value, err := a.resolveEntry(e)
+		return value, true, err
+	}
+
+	value, cached, err := a.compute(ctx, key, lock, action)
+	if err != nil && !cached && found && e.isGraceful(time.Now(), a.staleTtl, a.staleIfErrorTtl) {
+		// Errors from storing the value are left to the post-action error handler.
+		var postErr *postActionError[T]
+		if !errors.As(err, &postErr) {
+			a.o.Logger.Error(err)
+			a.o.Logger.Debug("serving stale value on error", key)
+			return e.Value, false, &StaleError{Err: err}
+		}
+	}
+
+	return value, cached, err
+}
+
+func (a defaultActor[T]) refresh(ctx context.Context, keyable Keyable, action ActionFunc[T]) (T, error) {
+	key, err := a.getKey(ctx, keyable)
+	if err != nil {
+		return zeroOf[T](), newPreActionError("key", "error while creating key", err)
+	}
+
+	lockKey := a.getLockKey(key)
+
+	lock, err := a.obtainLock(ctx, lockKey)
+	if err != nil {
+		return zeroOf[T](), err
+	}
+	defer a.releaseLock(ctx, lockKey, lock)
+
+	// The previous value is left in place until it is overwritten.
+	value, _, err := a.compute(ctx, key, lock, action)
+	return value, err
+}
+
+// Perform the action and store its result. Returns whether the result is cached.
+// The result is only stored if the lock has not been superseded, given that the adapter supports fencing.
+func (a defaultActor[T]) compute(ctx context.Context, key string, lock adapter.Lock, action ActionFunc[T]) (T, bool, error) {
+	token := getLockToken(lock)
+
+	epoch, err := a.getEpoch(ctx, key)
+	if err != nil {
+		return zeroOf[T](), false, newPreActionError("get", "error while getting epoch", err)
+	}
+
+	result, delta, err := a.perform(ctx, key, action)
+
+	// Results of actions started before an invalidation are not stored.
+	invalidated, epochErr := a.isInvalidatedSince(ctx, key, epoch)
+
+	if err != nil {
+		if invalidated || epochErr != nil {
+			return zeroOf[T](), false, err
+		}
 
-			lock, err := a.o.Adapter.ObtainLock(ctx, lockKey)
-			if err != nil {
-				return zeroOf[T](), newPreActionError("lock", "error while attempting to lock", err)
+		cached := a.storeError(ctx, key, err, delta, token)
+		if cached {
+			kept, invErr := a.discardIfInvalidated(ctx, key, epoch)
+			if invErr != nil {
+				a.o.Logger.Error("error while checking invalidation", invErr)
 			}
-			defer func() {
-				lock.Release(ctx)
-				a.o.Logger.Debug("lock released", lockKey)
-			}()
-			a.o.Logger.Debug("lock acquired", lockKey)
-
-			// Check for a second time.
-			// This is required because one or more processes/threads might have already reached the locking stage.
-			value, err := a.getValue(ctx, key)
-			if err != nil {
-				if errors.Is(err, adapter.ErrNotFound) {
-					a.o.Logger.Debug("perform action", key)
-
-					result, err := action(ctx)
-					if err != nil {
-						return zeroOf[T](), err
-					}
-
-					if err := a.storeValue(ctx, key, result); err != nil {
-						return zeroOf[T](), newPostActionError("store", "error while storing value", result, err)
-					}
-
-					return result.Value, nil
-				}
-
-				return zeroOf[T](), newPreActionError("get", "error while getting value", err)
+			cached = kept
+		}
+		return zeroOf[T](), cached, err
+	}
+
+	if epochErr != nil {
+		return zeroOf[T](), false, newPostActionError("get", "error while getting epoch", result, epochErr)
+	}
+
+	if invalidated {
+		a.o.Logger.Debug("invalidated during action", key)
+		return result.Value, false, nil
+	}
+
+	if err := a.storeValue(ctx, key, result, delta, token); err != nil {
+		return zeroOf[T](), false, newPostActionError("store", "error while storing value", result, err)
+	}
+
+	if result.Cache {
+		kept, err := a.discardIfInvalidated(ctx, key, epoch)
+		if err != nil {
+			return zeroOf[T](), false, newPostActionError("store", "error while checking invalidation", result, err)
+		}
+
+		if !kept {
+			return result.Value, false, nil
+		}
+	}
+
+	return result.Value, result.Cache, nil
+}
+
+func (a defaultActor[T]) revalidate(ctx context.Context, key string, stale entry[T], action ActionFunc[T]) {
+	done, ok := a.beginRevalidation(key)
+	if !ok {
+		return
+	}
+
+	// The refresh must not be cancelled along with the request that triggered it.
+	ctx = context.WithoutCancel(ctx)
+
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		defer done()
+		defer a.recoverBackground(key)
+
+		if err := a.recompute(ctx, key, stale.ExpiresAt, action); err != nil {
+			a.o.Logger.Error(err)
+		}
+	}()
+}
+
+// Log panics of actions performed in background, which would otherwise crash the process as there is no caller to
+// propagate them to. Must be deferred directly.
+func (a defaultActor[T]) recoverBackground(key string) {
+	if r := recover(); r != nil {
+		err := &PanicError{Value: r, Stack: debug.Stack()}
+		a.o.Logger.Error("background action panicked", key, err, string(err.Stack))
+	}
+}
+
+// Only a single background refresh per key is allowed within this process.
+func (a defaultActor[T]) beginRevalidation(key string) (func(), bool) {
+	if _, ok := a.revalidating.LoadOrStore(key, struct{}{}); ok {
+		return nil, false
+	}
+
+	return func() { a.revalidating.Delete(key) }, true
+}
+
+// Recompute the value under lock, unless it has been replaced since it was seen with the given expiry.
+func (a defaultActor[T]) recompute(ctx context.Context, key string, seenExpiresAt int64, action ActionFunc[T]) error {
+	lockKey := a.getLockKey(key)
+
+	lock, err := a.obtainLock(ctx, lockKey)
+	if err != nil {
+		return err
+	}
+	defer a.releaseLock(ctx, lockKey, lock)
+
+	// Another process might have already refreshed the value while waiting for the lock.
+	if e, err := a.getEntry(ctx, key); err == nil && e.ExpiresAt != seenExpiresAt {
+		return nil
+	}
+
+	a.o.Logger.Debug("revalidate", key)
+
+	_, _, err = a.compute(ctx, key, lock, action)
+	return err
+}
+
+func (a defaultActor[T]) perform(ctx context.Context, key string, action ActionFunc[T]) (result ActionResult[T], delta time.Duration, err error) {
+	a.o.Logger.Debug("perform action", key)
+
+	start := time.Now()
+	defer func() {
+		delta = time.Since(start)
+	}()
+
+	if a.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = ActionResult[T]{}, &PanicError{Value: r, Stack: debug.Stack()}
 			}
+		}()
+	}
 
-			// Post-lock value get.
-			return value, nil
+	result, err = action(ctx)
+	return result, 0, err
+}
+
+// Obtain the lock, waiting no longer than the lock timeout if set.
+func (a defaultActor[T]) obtainLock(ctx context.Context, lockKey string) (adapter.Lock, error) {
+	lockCtx := ctx
+	if a.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, a.lockTimeout)
+		defer cancel()
+	}
+
+	lock, err := a.o.Adapter.ObtainLock(lockCtx, lockKey)
+	if err != nil {
+		// Only the lock timeout is reported as such, not the deadline of the caller.
+		if ctx.Err() == nil && lockCtx.Err() != nil {
+			a.o.Logger.Debug("lock timed out", lockKey)
+			return nil, ErrLockTimeout
 		}
 
-		return zeroOf[T](), newPreActionError("get", "error while getting value", err)
+		return nil, newPreActionError("lock", "error while attempting to lock", err)
 	}
 
-	// Pre-lock value get.
-	return value, nil
+	a.o.Logger.Debug("lock acquired", lockKey)
+	return watchLock(lock, lockKey, a.o.Logger), nil
+}
+
+// Load the value according to the lock timeout policy. Returns whether the value is cached.
+func (a defaultActor[T]) loadOnLockTimeout(ctx context.Context, key string, action ActionFunc[T]) (T, bool, error) {
+	switch a.lockTimeoutPolicy {
+	case LockTimeoutRunAction:
+		result, _, err := a.perform(ctx, key, action)
+		if err != nil {
+			return zeroOf[T](), false, err
+		}
+		return result.Value, false, nil
+
+	case LockTimeoutStale:
+		e, err := a.getEntry(ctx, key)
+		if err != nil || e.Err != nil {
+			return zeroOf[T](), false, ErrLockTimeout
+		}
+		if e.isFresh(time.Now()) {
+			return e.Value, true, nil
+		}
+		return e.Value, false, &StaleError{Err: ErrLockTimeout}
+
+	case LockTimeoutPoll:
+		value, found, err := a.pollEntry(ctx, key)
+		if found || err != nil {
+			return value, found, err
+		}
+		return a.loadAfterPoll(ctx, key, action)
+	}
+
+	return zeroOf[T](), false, ErrLockTimeout
+}
+
+// Wait for the value to be stored by the holder of the lock, for at most the lock timeout. Returns whether the value is found.
+func (a defaultActor[T]) pollEntry(ctx context.Context, key string) (T, bool, error) {
+	ticker := time.NewTicker(LockPollIntervalDefault)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(a.lockTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return zeroOf[T](), false, ctx.Err()
+		case <-timer.C:
+			return zeroOf[T](), false, nil
+		case <-ticker.C:
+		}
+
+		e, err := a.getEntry(ctx, key)
+		if err != nil && !errors.Is(err, adapter.ErrNotFound) {
+			return zeroOf[T](), false, newPreActionError("get", "error while getting value", err)
+		}
+
+		if err == nil && e.isFresh(time.Now()) {
+			a.o.Logger.Debug("polled value", key)
+			value, err := a.resolveEntry(e)
+			return value, true, err
+		}
+	}
+}
+
+// Attempt the lock once more after polling in vain, as the holder might have released it without storing a value.
+// Returns ErrLockTimeout if the lock is still held.
+func (a defaultActor[T]) loadAfterPoll(ctx context.Context, key string, action ActionFunc[T]) (T, bool, error) {
+	lockKey := a.getLockKey(key)
+
+	lock, err := a.obtainLock(ctx, lockKey)
+	if err != nil {
+		return zeroOf[T](), false, err
+	}
+	defer a.releaseLock(ctx, lockKey, lock)
+
+	return a.loadLocked(ctx, key, lock, action)
+}
+
+func (a defaultActor[T]) releaseLock(ctx context.Context, lockKey string, lock adapter.Lock) {
+	lock.Release(ctx)
+	a.o.Logger.Debug("lock released", lockKey)
 }
 
-func (a defaultActor[T]) getKey(keyable Keyable) (string, error) {
+// Get the fencing token of the lock, or zero if it has none.
+func getLockToken(lock adapter.Lock) int64 {
+	if wl, ok := lock.(*watchedLock); ok {
+		lock = wl.ExtendableLock
+	}
+
+	if fl, ok := lock.(adapter.FencedLock); ok {
+		return fl.Token()
+	}
+
+	return 0
+}
+
+func (a defaultActor[T]) getKey(ctx context.Context, keyable Keyable) (string, error) {
 	key, err := keyable.Key()
 	if err != nil {
 		return "", err
@@ -187,56 +815,287 @@ func (a defaultActor[T]) getKey(keyable Keyable) (string, error) {
 
 	a.o.Logger.Debug("name", a.name, "key", key)
 
-	// Prefix the key string with name.
-	return a.name + "###" + key, nil
+	prefix, err := a.getKeyPrefix(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + key, nil
+}
+
+func (a defaultActor[T]) getKeyPrefix(ctx context.Context) (string, error) {
+	if !a.generations {
+		// Prefix the key string with name.
+		return a.name + "###", nil
+	}
+
+	generation, err := a.getGeneration(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Prefix the key string with name and generation.
+	return a.name + "@" + strconv.FormatInt(generation, 10) + "###", nil
+}
+
+func (a defaultActor[T]) getGeneration(ctx context.Context) (int64, error) {
+	data, err := a.o.Adapter.Get(ctx, a.getGenerationKey())
+	if err != nil {
+		if errors.Is(err, adapter.ErrNotFound) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func (a defaultActor[T]) getGenerationKey() string {
+	return adapter.GenerationKeyPrefix + a.name
+}
+
+func (a defaultActor[T]) getEpochKey(key string) string {
+	return adapter.EpochKeyPrefix + key
+}
+
+// Get the invalidation epoch of the key, or zero if epochs are disabled.
+func (a defaultActor[T]) getEpoch(ctx context.Context, key string) (int64, error) {
+	if !a.epochs {
+		return 0, nil
+	}
+
+	data, err := a.o.Adapter.Get(ctx, a.getEpochKey(key))
+	if err != nil {
+		if errors.Is(err, adapter.ErrNotFound) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func (a defaultActor[T]) isInvalidatedSince(ctx context.Context, key string, epoch int64) (bool, error) {
+	if !a.epochs {
+		return false, nil
+	}
+
+	current, err := a.getEpoch(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	return current != epoch, nil
+}
+
+// Delete the stored result if the key has been invalidated since the given epoch. Returns whether the result is kept.
+//
+// An invalidation might have happened between checking the epoch and storing the result, in which case the epoch
+// has already been bumped when checked again here, since it is bumped before deleting.
+func (a defaultActor[T]) discardIfInvalidated(ctx context.Context, key string, epoch int64) (bool, error) {
+	invalidated, err := a.isInvalidatedSince(ctx, key, epoch)
+	if err != nil {
+		return false, err
+	}
+
+	if !invalidated {
+		return true, nil
+	}
+
+	a.o.Logger.Debug("invalidated while storing", key)
+
+	if err := a.o.Adapter.Delete(ctx, key); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func (a defaultActor[T]) getLockKey(key string) string {
+	return "lock###" + key
 }
 
-func (a defaultActor[T]) getValue(ctx context.Context, key string) (T, error) {
+func (a defaultActor[T]) getEntry(ctx context.Context, key string) (entry[T], error) {
 	data, err := a.o.Adapter.Get(ctx, key)
 	if err != nil {
-		return zeroOf[T](), err
+		return entry[T]{}, err
 	}
 
 	a.o.Logger.Debug("get value", key)
 
-	var value T
-	if err := a.o.Codec.Unmarshal(data, &value); err != nil {
-		return zeroOf[T](), err
+	var e entry[T]
+	if err := a.o.Codec.Unmarshal(data, &e); err != nil {
+		return entry[T]{}, err
 	}
 
-	return value, nil
+	// Data stored in another format might decode into an empty envelope, which would never expire.
+	if e.Version != entryVersion {
+		a.o.Logger.Debug("unknown entry version", key)
+		return entry[T]{}, adapter.ErrNotFound
+	}
+
+	return e, nil
 }
 
-func (a defaultActor[T]) storeValue(ctx context.Context, key string, result ActionResult[T]) error {
+func (a defaultActor[T]) storeValue(ctx context.Context, key string, result ActionResult[T], delta time.Duration, token int64) error {
 	if !result.Cache {
 		a.o.Logger.Debug("not caching", key)
 		return nil
 	}
 
-	ttl := result.TTL
-	if ttl <= 0 {
-		// If for some reason it is also zero. Don't bother caching it.
-		if a.ttl < 0 {
-			return nil
-		}
-
-		ttl = a.ttl
+	ttl, ok := a.getTTL(result)
+	if !ok {
+		return nil
 	}
 
 	a.o.Logger.Debug("store value", key)
 
-	data, err := a.o.Codec.Marshal(&result.Value)
+	// Tagged first, as a value stored without its tags could never be invalidated by them.
+	if err := a.tagKey(ctx, key, ttl, result.Tags); err != nil {
+		return err
+	}
+
+	return a.storeEntry(ctx, key, newEntry(result.Value, ttl, delta), ttl, token)
+}
+
+// Store the error returned by the action if it is cacheable. Returns whether the error is cached.
+func (a defaultActor[T]) storeError(ctx context.Context, key string, actionErr error, delta time.Duration, token int64) bool {
+	// Recovered panics are never cached.
+	var panicErr *PanicError
+	if errors.As(actionErr, &panicErr) {
+		return false
+	}
+
+	target, ok := a.matchCacheableError(actionErr)
+	if !ok || a.negativeTtl <= 0 {
+		return false
+	}
+
+	a.o.Logger.Debug("store error", key)
+
+	if err := a.storeEntry(ctx, key, newErrorEntry[T](actionErr, target, a.negativeTtl, delta), a.negativeTtl, token); err != nil {
+		a.o.Logger.Error("error while storing error", err)
+		return false
+	}
+
+	return true
+}
+
+// Store the entry, fenced by the given token unless it is zero.
+func (a defaultActor[T]) storeEntry(ctx context.Context, key string, e entry[T], ttl time.Duration, token int64) error {
+	data, err := a.o.Codec.Marshal(&e)
 	if err != nil {
 		return err
 	}
 
-	if err := a.o.Adapter.Set(ctx, key, ttl, data); err != nil {
+	if fa, ok := a.o.Adapter.(adapter.FencingAdapter); ok && token != 0 {
+		stored, err := fa.SetFenced(ctx, key, a.getStorageTTL(ttl), data, token)
+		if err != nil {
+			return err
+		}
+
+		// A newer holder of the lock has already written the key.
+		if !stored {
+			a.o.Logger.Debug("stale write rejected", key)
+		}
+		return nil
+	}
+
+	if err := a.o.Adapter.Set(ctx, key, a.getStorageTTL(ttl), data); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+func (a defaultActor[T]) tagKey(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	ta, ok := a.o.Adapter.(adapter.TagAdapter)
+	if !ok {
+		return adapter.ErrNotSupported
+	}
+
+	tagKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		tagKeys[i] = getTagKey(tag)
+	}
+
+	return ta.Tag(ctx, key, a.getStorageTTL(ttl), tagKeys)
+}
+
+// Returns the TTL of the result, falling back to the default TTL.
+func (a defaultActor[T]) getTTL(result ActionResult[T]) (time.Duration, bool) {
+	if result.TTL > 0 {
+		return result.TTL, true
+	}
+
+	// If for some reason it is also zero. Don't bother caching it.
+	if a.ttl < 0 {
+		return 0, false
+	}
+
+	return a.ttl, true
+}
+
+// Returns the TTL of the entry in the adapter for the given TTL of the value.
+func (a defaultActor[T]) getStorageTTL(ttl time.Duration) time.Duration {
+	// Values which never expire are not stale either.
+	if ttl <= 0 {
+		return 0
+	}
+
+	// Keep the entry physically stored throughout its stale period and the grace period for errors.
+	return ttl + a.staleTtl + a.staleIfErrorTtl
+}
+
+// Find whether the error returned by the action is cacheable, along with the matching target if any.
+func (a defaultActor[T]) matchCacheableError(err error) (error, bool) {
+	for _, target := range a.cacheableErrs {
+		if errors.Is(err, target) {
+			return target, true
+		}
+	}
+
+	if a.cacheableErrFunc != nil && a.cacheableErrFunc(err) {
+		return nil, true
+	}
+
+	return nil, false
+}
+
+// Returns the value of the entry, or the error rebuilt from the entry.
+func (a defaultActor[T]) resolveEntry(e entry[T]) (T, error) {
+	if e.Err == nil {
+		return e.Value, nil
+	}
+
+	cachedErr := &CachedError{Message: e.Err.Message}
+	if e.Err.Target != "" {
+		for _, target := range a.cacheableErrs {
+			if target.Error() == e.Err.Target {
+				cachedErr.target = target
+				break
+			}
+		}
+	}
+
+	return zeroOf[T](), cachedErr
+}
+
+// Tags are not prefixed by actor name, as they are shared across actors.
+func getTagKey(tag string) string {
+	return "tag###" + tag
+}
+
+func DefaultActionErrorHandler[T any](ctx context.Context, args ActionErrorHandlerArgs[T]) (T, error) {
+	// Return the recovered panic as is.
+	return zeroOf[T](), args.Err
+}
+
 func DefaultPreActionErrorHandler[T any](ctx context.Context, args PreActionErrorHandlerArgs[T]) (T, error) {
 	// Allow the action to execute in case of errors made when hitting cache.
 	// Does not store the result in cache.
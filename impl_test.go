@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ezraisw/wracha"
 	"github.com/ezraisw/wracha/adapter"
 	"github.com/ezraisw/wracha/adapter/memory"
+	"github.com/ezraisw/wracha/adapter/tiered"
 	"github.com/ezraisw/wracha/codec"
+	"github.com/ezraisw/wracha/codec/json"
 	"github.com/ezraisw/wracha/codec/msgpack"
 	"github.com/ezraisw/wracha/logger"
 	"github.com/ezraisw/wracha/logger/std"
@@ -125,6 +129,138 @@ func (a proxiedAdapter) ObtainLock(ctx context.Context, key string) (adapter.Loc
 	return a.adapter.ObtainLock(ctx, key)
 }
 
+func (a proxiedAdapter) SetFenced(ctx context.Context, key string, ttl time.Duration, data []byte, token int64) (bool, error) {
+	if a.setOverride != nil {
+		return true, a.setOverride(ctx, key, ttl, data)
+	}
+	return a.adapter.(adapter.FencingAdapter).SetFenced(ctx, key, ttl, data, token)
+}
+
+func (a proxiedAdapter) GetMany(ctx context.Context, keys []string) ([][]byte, error) {
+	return a.adapter.(adapter.BatchAdapter).GetMany(ctx, keys)
+}
+
+func (a proxiedAdapter) SetMany(ctx context.Context, items []adapter.Item) error {
+	return a.adapter.(adapter.BatchAdapter).SetMany(ctx, items)
+}
+
+func (a proxiedAdapter) Tag(ctx context.Context, key string, ttl time.Duration, tags []string) error {
+	return a.adapter.(adapter.TagAdapter).Tag(ctx, key, ttl, tags)
+}
+
+func (a proxiedAdapter) DeleteTag(ctx context.Context, tag string) error {
+	return a.adapter.(adapter.TagAdapter).DeleteTag(ctx, tag)
+}
+
+func (a proxiedAdapter) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return a.adapter.(adapter.CounterAdapter).Incr(ctx, key, ttl)
+}
+
+func (a proxiedAdapter) DeletePrefix(ctx context.Context, prefix string) error {
+	return a.adapter.(adapter.PrefixAdapter).DeletePrefix(ctx, prefix)
+}
+
+type extendableLock struct {
+	adapter.Lock
+
+	ttl     time.Duration
+	extends *atomic.Int32
+}
+
+func (l extendableLock) Extend(ctx context.Context) error {
+	l.extends.Add(1)
+	return nil
+}
+
+func (l extendableLock) TTL() time.Duration {
+	return l.ttl
+}
+
+// Allows the lock to be released early, as if its lease has expired.
+type expirableLock struct {
+	adapter.FencedLock
+	once *sync.Once
+}
+
+func (l expirableLock) Release(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		err = l.FencedLock.Release(ctx)
+	})
+	return err
+}
+
+// Delivers invalidations between endpoints within the process, in place of a bus over Redis.
+// Logger forwarding every log, which also reports logged errors.
+type recordingLogger struct {
+	logger.Logger
+	errs chan []any
+}
+
+func (l recordingLogger) Error(args ...any) {
+	l.Logger.Error(args...)
+
+	select {
+	case l.errs <- args:
+	default:
+	}
+}
+
+type localBusHub struct {
+	mu       sync.Mutex
+	handlers map[*localBus]adapter.InvalidationHandler
+}
+
+type localBus struct {
+	hub *localBusHub
+}
+
+func (h *localBusHub) connect() *localBus {
+	return &localBus{hub: h}
+}
+
+func (b *localBus) Publish(ctx context.Context, invalidation adapter.Invalidation) error {
+	b.hub.mu.Lock()
+	defer b.hub.mu.Unlock()
+
+	for other, handler := range b.hub.handlers {
+		if other != b {
+			handler.Invalidate(ctx, invalidation)
+		}
+	}
+	return nil
+}
+
+func (b *localBus) Subscribe(ctx context.Context, handler adapter.InvalidationHandler) error {
+	b.hub.mu.Lock()
+	b.hub.handlers[b] = handler
+	b.hub.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.hub.mu.Lock()
+		delete(b.hub.handlers, b)
+		b.hub.mu.Unlock()
+	}()
+	return nil
+}
+
+// Hides the optional capabilities of the wrapped adapter.
+type untaggedAdapter struct {
+	adapter.Adapter
+}
+
+type failingBus struct{}
+
+func (failingBus) Publish(ctx context.Context, invalidation adapter.Invalidation) error {
+	return errMock
+}
+
+func (failingBus) Subscribe(ctx context.Context, handler adapter.InvalidationHandler) error {
+	return errMock
+}
+
 func makeAction[T any](run *bool, result wracha.ActionResult[T], err error) wracha.ActionFunc[T] {
 	return func(context.Context) (wracha.ActionResult[T], error) {
 		*run = true
@@ -601,6 +737,1346 @@ func (s *ManagerTestSuite) TestSetTTL() {
 	runCases(context.Background(), s, actor, cases)
 }
 
+func (s *ManagerTestSuite) TestZeroTTL() {
+	s.adapter.setOverride = func(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+		s.Assert().Equal(time.Duration(0), ttl)
+		return s.adapter.adapter.Set(ctx, key, ttl, data)
+	}
+
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetTTL(0).SetStaleTTL(time.Duration(1) * time.Minute)
+
+	// Values without TTL never expire, so the action must not be performed again.
+	runCases(context.Background(), s, actor, []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+			postAction: func() {
+				time.Sleep(time.Duration(100) * time.Millisecond)
+			},
+		},
+		{
+			key:           wracha.KeyableStr("testing-key"),
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	})
+}
+
+func (s *ManagerTestSuite) TestValueOfPreviousFormat() {
+	for _, c := range []codec.Codec{msgpack.NewCodec(), json.NewCodec()} {
+		s.SetupTest()
+
+		actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+			Adapter: s.adapter,
+			Codec:   c,
+			Logger:  s.logger,
+		})
+
+		// Stored as a plain value without expiry, before values were wrapped in an envelope.
+		data, err := c.Marshal(&dummyValue1)
+		s.Require().Nil(err)
+		s.Require().Nil(s.adapter.Set(context.Background(), "testing###testing-key", 0, data))
+
+		run := false
+		value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), makeAction(&run, wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue2,
+		}, nil))
+		s.Assert().Nil(err)
+		s.Assert().True(run)
+		s.Assert().Equal(dummyValue2, value)
+
+		// Replaced by the new format.
+		run = false
+		value, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), makeAction(&run, wracha.ActionResult[testStruct]{}, nil))
+		s.Assert().Nil(err)
+		s.Assert().False(run)
+		s.Assert().Equal(dummyValue2.Name, value.Name)
+	}
+}
+
+func (s *ManagerTestSuite) TestStaleWhileRevalidate() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetStaleTTL(time.Duration(2) * time.Second)
+
+	duration := time.Duration(1) * time.Second
+
+	runCases(context.Background(), s, actor, []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				TTL:   duration,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+			postAction: func() {
+				time.Sleep(duration + 100*time.Millisecond)
+			},
+		},
+	})
+
+	refreshed := make(chan struct{})
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		defer close(refreshed)
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			TTL:   duration,
+			Value: dummyValue2,
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	select {
+	case <-refreshed:
+	case <-time.After(duration):
+		s.FailNow("value was not refreshed")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	runCases(context.Background(), s, actor, []tCase[testStruct]{
+		{
+			key:           wracha.KeyableStr("testing-key"),
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+	})
+}
+
+func (s *ManagerTestSuite) TestEarlyRecompute() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetEarlyRecomputeBeta(1e9)
+
+	runCases(context.Background(), s, actor, []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			action: func(context.Context) (wracha.ActionResult[testStruct], error) {
+				time.Sleep(10 * time.Millisecond)
+				return wracha.ActionResult[testStruct]{
+					Cache: true,
+					Value: dummyValue1,
+				}, nil
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	})
+
+	refreshed := make(chan struct{})
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		defer close(refreshed)
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue2,
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		s.FailNow("value was not recomputed")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	value, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		return wracha.ActionResult[testStruct]{}, errMock
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue2, value)
+}
+
+func (s *ManagerTestSuite) TestConcurrentActionsCollapsed() {
+	var lockCount atomic.Int32
+	s.adapter.obtainLockOverride = func(ctx context.Context, key string) (adapter.Lock, error) {
+		lockCount.Add(1)
+		return s.adapter.adapter.ObtainLock(ctx, key)
+	}
+
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	var runCount atomic.Int32
+	action := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		runCount.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+			s.Assert().Nil(err)
+			s.Assert().Equal(dummyValue1, value)
+		}()
+	}
+	wg.Wait()
+
+	s.Assert().Equal(int32(1), runCount.Load())
+	s.Assert().Equal(int32(1), lockCount.Load())
+}
+
+func (s *ManagerTestSuite) TestConcurrentActionsSharingUncachedResults() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetShareUncachedResults(true)
+
+	var runCount atomic.Int32
+	action := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		runCount.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return wracha.ActionResult[testStruct]{
+			Cache: false,
+			Value: dummyValue1,
+		}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+			s.Assert().Nil(err)
+			s.Assert().Equal(dummyValue1, value)
+		}()
+	}
+	wg.Wait()
+
+	s.Assert().Equal(int32(1), runCount.Load())
+}
+
+func (s *ManagerTestSuite) TestDetachedAction() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetDetachedAction(time.Duration(1) * time.Second)
+
+	var runCount atomic.Int32
+	action := func(ctx context.Context) (wracha.ActionResult[testStruct], error) {
+		runCount.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			return wracha.ActionResult[testStruct]{}, err
+		}
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The caller gives up, but the action keeps running.
+	_, err := actor.Do(ctx, wracha.KeyableStr("testing-key"), action)
+	s.Assert().ErrorIs(err, context.DeadlineExceeded)
+
+	time.Sleep(300 * time.Millisecond)
+
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+	s.Assert().Equal(int32(1), runCount.Load())
+}
+
+func (s *ManagerTestSuite) TestDetachedActionPanic() {
+	recorder := recordingLogger{Logger: s.logger, errs: make(chan []any, 1)}
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  recorder,
+	}).SetDetachedAction(time.Duration(1) * time.Second)
+
+	panicking := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		time.Sleep(100 * time.Millisecond)
+		panic(errMock)
+	}
+
+	// The leader panics with the recovered value along with the stack of the action.
+	func() {
+		defer func() {
+			r := recover()
+			s.Require().NotNil(r)
+
+			err, ok := r.(error)
+			s.Require().True(ok)
+
+			var panicErr *wracha.PanicError
+			s.Require().ErrorAs(err, &panicErr)
+			s.Assert().Equal(errMock, panicErr.Value)
+			s.Assert().Contains(string(panicErr.Stack), "TestDetachedActionPanic")
+			s.Assert().Contains(err.Error(), "TestDetachedActionPanic")
+		}()
+
+		actor.Do(context.Background(), wracha.KeyableStr("testing-key"), panicking)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// The leader stops waiting, so the panic is logged instead.
+	_, err := actor.Do(ctx, wracha.KeyableStr("testing-key"), panicking)
+	s.Assert().ErrorIs(err, context.DeadlineExceeded)
+
+	select {
+	case args := <-recorder.errs:
+		s.Assert().Equal("abandoned action panicked", args[0])
+	case <-time.After(time.Second):
+		s.Fail("panic was not logged")
+	}
+}
+
+func (s *ManagerTestSuite) TestRevalidationPanic() {
+	recorder := recordingLogger{Logger: s.logger, errs: make(chan []any, 1)}
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  recorder,
+	}).SetTTL(time.Duration(50) * time.Millisecond).SetStaleTTL(time.Duration(1) * time.Minute)
+
+	s.Require().Nil(actor.Set(context.Background(), wracha.KeyableStr("testing-key"), dummyValue1, 0))
+	time.Sleep(time.Duration(100) * time.Millisecond)
+
+	// The panic of the background refresh must not crash the process.
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		panic(errMock)
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	s.Require().Nil(actor.Close())
+
+	select {
+	case args := <-recorder.errs:
+		s.Assert().Equal("background action panicked", args[0])
+		s.Assert().Contains(args[3], "TestRevalidationPanic")
+	default:
+		s.Fail("panic was not logged before close returned")
+	}
+}
+
+func (s *ManagerTestSuite) TestRecoveredPanic() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetRecoverPanics(true)
+
+	panicking := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		panic(errMock)
+	}
+
+	_, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), panicking)
+
+	var panicErr *wracha.PanicError
+	s.Require().ErrorAs(err, &panicErr)
+	s.Assert().ErrorIs(err, errMock)
+	s.Assert().NotEmpty(panicErr.Stack)
+
+	actor.SetActionErrorHandler(func(ctx context.Context, args wracha.ActionErrorHandlerArgs[testStruct]) (testStruct, error) {
+		return dummyValue2, nil
+	})
+
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), panicking)
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue2, value)
+
+	// The lock must have been released.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(1)*time.Second)
+	defer cancel()
+
+	value, err = actor.Do(ctx, wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+}
+
+func (s *ManagerTestSuite) TestLockTimeout() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetStaleIfError(time.Duration(1) * time.Minute)
+
+	action := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	}
+
+	// Hold the lock as if another caller were performing the action.
+	lock, err := s.adapter.ObtainLock(context.Background(), "lock###testing###testing-key")
+	s.Require().Nil(err)
+
+	actor.SetLockTimeout(time.Duration(100)*time.Millisecond, wracha.LockTimeoutError)
+	_, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().ErrorIs(err, wracha.ErrLockTimeout)
+
+	actor.SetLockTimeout(time.Duration(100)*time.Millisecond, wracha.LockTimeoutRunAction)
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	// The result of the action must not have been cached.
+	_, ok, err := actor.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+
+	s.Require().Nil(actor.Set(context.Background(), wracha.KeyableStr("testing-key"), dummyValue2, time.Duration(50)*time.Millisecond))
+	time.Sleep(time.Duration(100) * time.Millisecond)
+
+	actor.SetLockTimeout(time.Duration(100)*time.Millisecond, wracha.LockTimeoutStale)
+	value, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().ErrorIs(err, wracha.ErrLockTimeout)
+	s.Assert().Equal(dummyValue2, value)
+
+	actor.SetLockTimeout(time.Duration(200)*time.Millisecond, wracha.LockTimeoutPoll)
+
+	// Store the value and release the lock while the next caller is polling.
+	go func() {
+		time.Sleep(time.Duration(300) * time.Millisecond)
+		s.Assert().Nil(actor.Set(context.Background(), wracha.KeyableStr("testing-key"), dummyValue1, 0))
+		s.Assert().Nil(lock.Release(context.Background()))
+	}()
+
+	value, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		s.Fail("action must not be performed")
+		return wracha.ActionResult[testStruct]{}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	s.Require().Nil(actor.Invalidate(context.Background(), wracha.KeyableStr("testing-key")))
+
+	// The holder releases the lock without storing a value, so the lock is attempted once more after polling.
+	lock, err = s.adapter.ObtainLock(context.Background(), "lock###testing###testing-key")
+	s.Require().Nil(err)
+	go func() {
+		time.Sleep(time.Duration(300) * time.Millisecond)
+		s.Assert().Nil(lock.Release(context.Background()))
+	}()
+
+	value, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	s.Require().Nil(actor.Invalidate(context.Background(), wracha.KeyableStr("testing-key")))
+
+	// The holder never releases the lock, while the context has no deadline.
+	lock, err = s.adapter.ObtainLock(context.Background(), "lock###testing###testing-key")
+	s.Require().Nil(err)
+	defer lock.Release(context.Background())
+
+	_, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().ErrorIs(err, wracha.ErrLockTimeout)
+}
+
+func (s *ManagerTestSuite) TestLockExtension() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	var extends atomic.Int32
+	s.adapter.obtainLockOverride = func(ctx context.Context, key string) (adapter.Lock, error) {
+		lock, err := s.adapter.adapter.ObtainLock(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return extendableLock{Lock: lock, ttl: time.Duration(30) * time.Millisecond, extends: &extends}, nil
+	}
+
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		time.Sleep(time.Duration(200) * time.Millisecond)
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	// Extended every 10ms while the action is running, and no longer after the lock is released.
+	count := extends.Load()
+	s.Assert().GreaterOrEqual(count, int32(10))
+
+	time.Sleep(time.Duration(50) * time.Millisecond)
+	s.Assert().Equal(count, extends.Load())
+}
+
+func (s *ManagerTestSuite) TestFencedWrite() {
+	options := wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}
+
+	slowActor := wracha.NewActor[testStruct]("testing", options)
+	fastActor := wracha.NewActor[testStruct]("testing", options)
+
+	var locks []expirableLock
+	s.adapter.obtainLockOverride = func(ctx context.Context, key string) (adapter.Lock, error) {
+		lock, err := s.adapter.adapter.ObtainLock(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		el := expirableLock{FencedLock: lock.(adapter.FencedLock), once: &sync.Once{}}
+		locks = append(locks, el)
+		return el, nil
+	}
+
+	value, err := slowActor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(ctx context.Context) (wracha.ActionResult[testStruct], error) {
+		// The lease expires, and another leader takes over.
+		s.Require().Nil(locks[0].Release(ctx))
+
+		value, err := fastActor.Do(ctx, wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+			return wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue2,
+			}, nil
+		})
+		s.Assert().Nil(err)
+		s.Assert().Equal(dummyValue2, value)
+
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+	s.Assert().Less(locks[0].Token(), locks[1].Token())
+
+	// The write of the superseded leader must have been rejected.
+	value, ok, err := slowActor.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(dummyValue2, value)
+}
+
+func (s *ManagerTestSuite) TestInvalidationDuringAction() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetInvalidationEpochs(true)
+
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(ctx context.Context) (wracha.ActionResult[testStruct], error) {
+		// The dependency changes while the value is being computed.
+		s.Assert().Nil(actor.Invalidate(ctx, wracha.KeyableStr("testing-key")))
+
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			Value: dummyValue1,
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	ok, err := actor.Has(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+
+	run := false
+	value, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), makeAction(&run, wracha.ActionResult[testStruct]{
+		Cache: true,
+		Value: dummyValue2,
+	}, nil))
+	s.Assert().Nil(err)
+	s.Assert().True(run)
+	s.Assert().Equal(dummyValue2, value)
+
+	ok, err = actor.Has(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+}
+
+func (s *ManagerTestSuite) TestInvalidationWhileStoring() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetInvalidationEpochs(true)
+
+	// The invalidation lands after the epoch has been checked, right before the value is written.
+	invalidated := false
+	s.adapter.setOverride = func(ctx context.Context, key string, ttl time.Duration, data []byte) error {
+		if key == "testing###testing-key" && !invalidated {
+			invalidated = true
+			s.Require().Nil(actor.Invalidate(ctx, wracha.KeyableStr("testing-key")))
+		}
+		return s.adapter.adapter.Set(ctx, key, ttl, data)
+	}
+
+	run := false
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), makeAction(&run, wracha.ActionResult[testStruct]{
+		Cache: true,
+		Value: dummyValue1,
+	}, nil))
+	s.Assert().Nil(err)
+	s.Assert().True(run)
+	s.Assert().True(invalidated)
+	s.Assert().Equal(dummyValue1, value)
+
+	ok, err := actor.Has(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+}
+
+func (s *ManagerTestSuite) TestTieredAdapter() {
+	local := memory.NewAdapter()
+	shared := memory.NewAdapter()
+
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: tiered.NewAdapterWithLocalTTL(local, shared, time.Duration(1)*time.Second),
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	run := false
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), makeAction(&run, wracha.ActionResult[testStruct]{
+		Cache: true,
+		Value: dummyValue1,
+	}, nil))
+	s.Assert().Nil(err)
+	s.Assert().True(run)
+	s.Assert().Equal(dummyValue1, value)
+
+	// Written to both tiers.
+	for _, a := range []adapter.Adapter{local, shared} {
+		ok, err := a.Exists(context.Background(), "testing###testing-key")
+		s.Assert().Nil(err)
+		s.Assert().True(ok)
+	}
+
+	// Filled back into the local tier from the shared tier.
+	s.Require().Nil(local.Delete(context.Background(), "testing###testing-key"))
+
+	value, ok, err := actor.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(dummyValue1, value)
+
+	ok, err = local.Exists(context.Background(), "testing###testing-key")
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+
+	// Deleted from both tiers.
+	s.Require().Nil(actor.Invalidate(context.Background(), wracha.KeyableStr("testing-key")))
+
+	for _, a := range []adapter.Adapter{local, shared} {
+		ok, err := a.Exists(context.Background(), "testing###testing-key")
+		s.Assert().Nil(err)
+		s.Assert().False(ok)
+	}
+}
+
+func (s *ManagerTestSuite) TestTieredAdapterCounters() {
+	shared := memory.NewAdapter()
+
+	newActor := func() (wracha.Actor[testStruct], adapter.Adapter) {
+		local := memory.NewAdapter()
+		return wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+			Adapter: tiered.NewAdapterWithLocalTTL(local, shared, time.Duration(1)*time.Minute),
+			Codec:   s.codec,
+			Logger:  s.logger,
+		}).SetGenerations(true), local
+	}
+
+	actor1, local1 := newActor()
+	actor2, _ := newActor()
+
+	s.Require().Nil(actor1.InvalidateAll(context.Background()))
+	s.Require().Nil(actor1.Set(context.Background(), wracha.KeyableStr("testing-key"), dummyValue1, 0))
+
+	value, ok, err := actor1.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(dummyValue1, value)
+
+	// Counters are never kept in the local tier.
+	ok, err = local1.Exists(context.Background(), "gen###testing")
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+
+	// Incremented by another process, which cannot evict the local tier of the first one without a bus.
+	s.Require().Nil(actor2.InvalidateAll(context.Background()))
+
+	_, ok, err = actor1.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+}
+
+func (s *ManagerTestSuite) TestTieredAdapterWithBus() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shared := memory.NewAdapter()
+	hub := &localBusHub{handlers: make(map[*localBus]adapter.InvalidationHandler)}
+
+	newActor := func() (wracha.Actor[testStruct], adapter.Adapter) {
+		local := memory.NewAdapter()
+
+		ta, err := tiered.NewAdapterWithBus(ctx, local, shared, time.Duration(1)*time.Minute, hub.connect())
+		s.Require().Nil(err)
+
+		return wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+			Adapter: ta,
+			Codec:   s.codec,
+			Logger:  s.logger,
+		}), local
+	}
+
+	actor1, _ := newActor()
+	actor2, local2 := newActor()
+
+	s.Require().Nil(actor1.Set(context.Background(), wracha.KeyableStr("testing-key"), dummyValue1, 0))
+
+	// Filled into the local tier of the other process.
+	value, ok, err := actor2.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(dummyValue1, value)
+
+	s.Require().Nil(actor1.Invalidate(context.Background(), wracha.KeyableStr("testing-key")))
+
+	ok, err = local2.Exists(context.Background(), "testing###testing-key")
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+
+	ok, err = actor2.Has(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+}
+
+func (s *ManagerTestSuite) TestTieredAdapterWithFailedBus() {
+	_, err := tiered.NewAdapterWithBus(context.Background(), memory.NewAdapter(), memory.NewAdapter(), time.Duration(1)*time.Minute, failingBus{})
+	s.Assert().ErrorIs(err, errMock)
+}
+
+func (s *ManagerTestSuite) TestActionWithCachedErrors() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetCacheableErrors(errMock).SetNegativeTTL(time.Duration(1) * time.Second)
+
+	cases := []tCase[testStruct]{
+		{
+			key:         wracha.KeyableStr("testing-key"),
+			err:         fmt.Errorf("wrapped: %w", errMock),
+			expectedErr: errMock,
+			mustRun:     true,
+		},
+		{
+			key:         wracha.KeyableStr("testing-key"),
+			expectedErr: errMock,
+			mustRun:     false,
+			postAction: func() {
+				time.Sleep(time.Duration(2) * time.Second)
+			},
+		},
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	}
+
+	runCases(context.Background(), s, actor, cases)
+}
+
+func (s *ManagerTestSuite) TestActionWithCachedErrorsByFunc() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetCacheableErrorFunc(func(err error) bool {
+		return err.Error() == "not found"
+	})
+
+	_, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		return wracha.ActionResult[testStruct]{}, errors.New("not found")
+	})
+	s.Assert().EqualError(err, "not found")
+
+	_, err = actor.Do(context.Background(), wracha.KeyableStr("testing-key"), func(context.Context) (wracha.ActionResult[testStruct], error) {
+		s.FailNow("action must not run")
+		return wracha.ActionResult[testStruct]{}, nil
+	})
+
+	var cachedErr *wracha.CachedError
+	s.Assert().ErrorAs(err, &cachedErr)
+	s.Assert().EqualError(err, "not found")
+}
+
+func (s *ManagerTestSuite) TestDoMany() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	runCases(context.Background(), s, actor, []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key-1"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	})
+
+	keys := []wracha.Keyable{
+		wracha.KeyableStr("testing-key-1"),
+		wracha.KeyableStr("testing-key-2"),
+		wracha.KeyableStr("testing-key-3"),
+		wracha.KeyableStr("testing-key-2"),
+	}
+
+	values, err := actor.DoMany(context.Background(), keys, func(ctx context.Context, missing []wracha.Keyable) (map[string]wracha.ActionResult[testStruct], error) {
+		s.Assert().Equal([]wracha.Keyable{wracha.KeyableStr("testing-key-2"), wracha.KeyableStr("testing-key-3")}, missing)
+		return map[string]wracha.ActionResult[testStruct]{
+			"testing-key-2": {
+				Cache: true,
+				Value: dummyValue2,
+			},
+		}, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(map[string]testStruct{
+		"testing-key-1": dummyValue1,
+		"testing-key-2": dummyValue2,
+	}, values)
+
+	values, err = actor.DoMany(context.Background(), keys, func(ctx context.Context, missing []wracha.Keyable) (map[string]wracha.ActionResult[testStruct], error) {
+		s.Assert().Equal([]wracha.Keyable{wracha.KeyableStr("testing-key-3")}, missing)
+		return nil, nil
+	})
+	s.Assert().Nil(err)
+	s.Assert().Equal(map[string]testStruct{
+		"testing-key-1": dummyValue1,
+		"testing-key-2": dummyValue2,
+	}, values)
+}
+
+func (s *ManagerTestSuite) TestActionWithInvalidatedTag() {
+	actor1 := wracha.NewActor[testStruct]("testing-1", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+	actor2 := wracha.NewActor[testStruct]("testing-2", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	for _, actor := range []wracha.Actor[testStruct]{actor1, actor2} {
+		runCases(context.Background(), s, actor, []tCase[testStruct]{
+			{
+				key: wracha.KeyableStr("testing-key"),
+				actionResult: wracha.ActionResult[testStruct]{
+					Cache: true,
+					Value: dummyValue1,
+					Tags:  []string{"testing-tag"},
+				},
+				mustRun:       true,
+				expectedErr:   nil,
+				expectedValue: dummyValue1,
+			},
+			{
+				key:           wracha.KeyableStr("testing-key"),
+				mustRun:       false,
+				expectedErr:   nil,
+				expectedValue: dummyValue1,
+			},
+		})
+	}
+
+	s.Assert().Nil(actor1.InvalidateTag(context.Background(), "testing-tag"))
+
+	for _, actor := range []wracha.Actor[testStruct]{actor1, actor2} {
+		runCases(context.Background(), s, actor, []tCase[testStruct]{
+			{
+				key: wracha.KeyableStr("testing-key"),
+				actionResult: wracha.ActionResult[testStruct]{
+					Cache: false,
+					Value: dummyValue2,
+				},
+				mustRun:       true,
+				expectedErr:   nil,
+				expectedValue: dummyValue2,
+			},
+		})
+	}
+}
+
+func (s *ManagerTestSuite) TestActionWithTagsNotSupported() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: untaggedAdapter{s.adapter},
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	runCases(context.Background(), s, actor, []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue1,
+				Tags:  []string{"testing-tag"},
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	})
+
+	// Values which cannot be tagged must not be cached, as they could never be invalidated by their tags.
+	exists, err := actor.Has(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().False(exists)
+}
+
+func (s *ManagerTestSuite) TestActionWithInvalidatedGeneration() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetGenerations(true)
+
+	cases := []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+		{
+			key:           wracha.KeyableStr("testing-key"),
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+			postAction: func() {
+				s.Assert().Nil(actor.InvalidateAll(context.Background()))
+			},
+		},
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue2,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+		{
+			key:           wracha.KeyableStr("testing-key"),
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+	}
+
+	runCases(context.Background(), s, actor, cases)
+}
+
+func (s *ManagerTestSuite) TestActionWithInvalidatedPrefix() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	keys := []wracha.KeyableStr{"tenant:42:a", "tenant:42:b", "tenant:43:a"}
+	for _, key := range keys {
+		runCases(context.Background(), s, actor, []tCase[testStruct]{
+			{
+				key: key,
+				actionResult: wracha.ActionResult[testStruct]{
+					Cache: true,
+					Value: dummyValue1,
+				},
+				mustRun:       true,
+				expectedErr:   nil,
+				expectedValue: dummyValue1,
+			},
+		})
+	}
+
+	s.Assert().Nil(actor.InvalidatePrefix(context.Background(), "tenant:42:"))
+
+	cases := []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("tenant:42:a"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: false,
+				Value: dummyValue2,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+		{
+			key: wracha.KeyableStr("tenant:42:b"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: false,
+				Value: dummyValue2,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+		{
+			key:           wracha.KeyableStr("tenant:43:a"),
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	}
+
+	runCases(context.Background(), s, actor, cases)
+}
+
+func (s *ManagerTestSuite) TestRefresh() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	cases := []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+			postAction: func() {
+				run := false
+				value, err := actor.Refresh(context.Background(), wracha.KeyableStr("testing-key"), makeAction(&run, wracha.ActionResult[testStruct]{
+					Cache: true,
+					Value: dummyValue2,
+				}, nil))
+				s.Assert().Nil(err)
+				s.Assert().Equal(dummyValue2, value)
+				s.Assert().True(run)
+			},
+		},
+		{
+			key:           wracha.KeyableStr("testing-key"),
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+	}
+
+	runCases(context.Background(), s, actor, cases)
+}
+
+func (s *ManagerTestSuite) TestSetAndPeek() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	})
+
+	ctx := context.Background()
+	key := wracha.KeyableStr("testing-key")
+
+	value, ok, err := actor.Peek(ctx, key)
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+	s.Assert().Equal(testStruct{}, value)
+
+	exists, err := actor.Has(ctx, key)
+	s.Assert().Nil(err)
+	s.Assert().False(exists)
+
+	s.Assert().Nil(actor.Set(ctx, key, dummyValue1, 0))
+
+	value, ok, err = actor.Peek(ctx, key)
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(dummyValue1, value)
+
+	exists, err = actor.Has(ctx, key)
+	s.Assert().Nil(err)
+	s.Assert().True(exists)
+
+	runCases(ctx, s, actor, []tCase[testStruct]{
+		{
+			key:           key,
+			mustRun:       false,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+		},
+	})
+}
+
+func (s *ManagerTestSuite) TestHasAgreesWithPeek() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetStaleIfError(time.Duration(1) * time.Minute)
+
+	ctx := context.Background()
+	key := wracha.KeyableStr("testing-key")
+
+	s.Require().Nil(actor.Set(ctx, key, dummyValue1, time.Duration(50)*time.Millisecond))
+	time.Sleep(time.Duration(100) * time.Millisecond)
+
+	// The value is only kept for when the action fails.
+	_, ok, err := actor.Peek(ctx, key)
+	s.Assert().Nil(err)
+	s.Assert().False(ok)
+
+	exists, err := actor.Has(ctx, key)
+	s.Assert().Nil(err)
+	s.Assert().False(exists)
+}
+
+func (s *ManagerTestSuite) TestStaleIfError() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetStaleIfError(time.Duration(5) * time.Second)
+
+	duration := time.Duration(1) * time.Second
+
+	cases := []tCase[testStruct]{
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				TTL:   duration,
+				Value: dummyValue1,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue1,
+			postAction: func() {
+				time.Sleep(duration + 100*time.Millisecond)
+			},
+		},
+		{
+			key:           wracha.KeyableStr("testing-key"),
+			err:           errMock,
+			mustRun:       true,
+			expectedErr:   errMock,
+			expectedValue: dummyValue1,
+		},
+		{
+			key: wracha.KeyableStr("testing-key"),
+			actionResult: wracha.ActionResult[testStruct]{
+				Cache: true,
+				Value: dummyValue2,
+			},
+			mustRun:       true,
+			expectedErr:   nil,
+			expectedValue: dummyValue2,
+		},
+	}
+
+	runCases(context.Background(), s, actor, cases)
+}
+
+func (s *ManagerTestSuite) TestRefreshAhead() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetRefreshAhead(wracha.RefreshAheadOptions{
+		Lead:     time.Duration(900) * time.Millisecond,
+		Interval: time.Duration(50) * time.Millisecond,
+		MinHits:  1,
+		Workers:  1,
+	})
+	defer actor.Close()
+
+	var runCount atomic.Int32
+	action := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		value := dummyValue1
+		if runCount.Add(1) > 1 {
+			value = dummyValue2
+		}
+
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			TTL:   time.Duration(1) * time.Second,
+			Value: value,
+		}, nil
+	}
+
+	value, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(err)
+	s.Assert().Equal(dummyValue1, value)
+
+	s.Assert().Eventually(func() bool {
+		return runCount.Load() > 1
+	}, time.Duration(1)*time.Second, time.Duration(10)*time.Millisecond)
+
+	s.Assert().Nil(actor.Close())
+	value, ok, err := actor.Peek(context.Background(), wracha.KeyableStr("testing-key"))
+	s.Assert().Nil(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(dummyValue2, value)
+}
+
+func (s *ManagerTestSuite) TestRefreshAheadLeadAndBackoff() {
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  s.logger,
+	}).SetTTL(time.Duration(1) * time.Hour).SetRefreshAhead(wracha.RefreshAheadOptions{
+		Interval: time.Duration(20) * time.Millisecond,
+		MinHits:  1,
+		Workers:  1,
+	})
+	defer actor.Close()
+
+	var runCount atomic.Int32
+	action := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		if runCount.Add(1) > 1 {
+			return wracha.ActionResult[testStruct]{}, errMock
+		}
+
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			TTL:   time.Duration(500) * time.Millisecond,
+			Value: dummyValue1,
+		}, nil
+	}
+
+	_, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(err)
+
+	// The lead is a tenth of the TTL of the value, not of the actor.
+	time.Sleep(time.Duration(300) * time.Millisecond)
+	s.Assert().Equal(int32(1), runCount.Load())
+
+	s.Assert().Eventually(func() bool {
+		return runCount.Load() > 1
+	}, time.Duration(500)*time.Millisecond, time.Duration(10)*time.Millisecond)
+
+	// Failed refreshes are retried with backoff rather than on every tick.
+	time.Sleep(time.Duration(400) * time.Millisecond)
+	s.Assert().LessOrEqual(runCount.Load(), int32(6))
+
+	// Stopping concurrently with reads is safe, and so is stopping twice.
+	go actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(actor.Close())
+}
+
+func (s *ManagerTestSuite) TestRefreshAheadPanic() {
+	recorder := recordingLogger{Logger: s.logger, errs: make(chan []any, 1)}
+	actor := wracha.NewActor[testStruct]("testing", wracha.ActorOptions{
+		Adapter: s.adapter,
+		Codec:   s.codec,
+		Logger:  recorder,
+	}).SetRefreshAhead(wracha.RefreshAheadOptions{
+		Interval: time.Duration(20) * time.Millisecond,
+		Lead:     time.Duration(400) * time.Millisecond,
+		MinHits:  1,
+		Workers:  1,
+	})
+	defer actor.Close()
+
+	var runCount atomic.Int32
+	action := func(context.Context) (wracha.ActionResult[testStruct], error) {
+		if runCount.Add(1) > 1 {
+			panic(errMock)
+		}
+
+		return wracha.ActionResult[testStruct]{
+			Cache: true,
+			TTL:   time.Duration(500) * time.Millisecond,
+			Value: dummyValue1,
+		}, nil
+	}
+
+	_, err := actor.Do(context.Background(), wracha.KeyableStr("testing-key"), action)
+	s.Assert().Nil(err)
+
+	// The panic of the refresh must not crash the process.
+	select {
+	case args := <-recorder.errs:
+		s.Assert().Equal("refresh action panicked", args[0])
+		s.Assert().Contains(args[3], "TestRefreshAheadPanic")
+	case <-time.After(time.Duration(1) * time.Second):
+		s.Fail("panic was not logged")
+	}
+
+	// Panicked refreshes are retried with backoff like failed ones.
+	time.Sleep(time.Duration(400) * time.Millisecond)
+	s.Assert().LessOrEqual(runCount.Load(), int32(6))
+	s.Assert().Nil(actor.Close())
+}
+
 func TestRunManagerTestSuite(t *testing.T) {
 	suite.Run(t, new(ManagerTestSuite))
 }
@@ -0,0 +1,279 @@
+package wracha
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+)
+
+type (
+	// Refreshes hot keys in background shortly before they expire.
+	refresher[T any] struct {
+		a *defaultActor[T]
+		o RefreshAheadOptions
+
+		mu   sync.Mutex
+		keys map[string]*trackedKey[T]
+
+		jobs     chan *trackedKey[T]
+		ctx      context.Context
+		cancel   context.CancelFunc
+		stopOnce sync.Once
+		wg       sync.WaitGroup
+	}
+
+	trackedKey[T any] struct {
+		key    string
+		action ActionFunc[T]
+
+		hits        int
+		windowStart time.Time
+		lastRead    time.Time
+
+		// The key is not checked again until this time, unless it is refreshed by the worker.
+		checkAfter time.Time
+		queued     bool
+
+		// Number of consecutive failed refreshes, delaying the next attempt.
+		failures int
+	}
+)
+
+const (
+	RefreshAheadIntervalDefault = time.Duration(1) * time.Second
+	RefreshAheadMinHitsDefault  = 2
+	RefreshAheadWindowDefault   = time.Duration(1) * time.Minute
+	RefreshAheadMaxKeysDefault  = 1000
+	RefreshAheadWorkersDefault  = 4
+)
+
+func newRefresher[T any](a *defaultActor[T], options RefreshAheadOptions) *refresher[T] {
+	if options.Interval <= 0 {
+		options.Interval = RefreshAheadIntervalDefault
+	}
+	if options.MinHits <= 0 {
+		options.MinHits = RefreshAheadMinHitsDefault
+	}
+	if options.Window <= 0 {
+		options.Window = RefreshAheadWindowDefault
+	}
+	if options.MaxKeys <= 0 {
+		options.MaxKeys = RefreshAheadMaxKeysDefault
+	}
+	if options.Workers <= 0 {
+		options.Workers = RefreshAheadWorkersDefault
+	}
+
+	return &refresher[T]{
+		a:    a,
+		o:    options,
+		keys: make(map[string]*trackedKey[T]),
+		jobs: make(chan *trackedKey[T], options.Workers),
+	}
+}
+
+func (r *refresher[T]) start() {
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	r.wg.Add(1)
+	go r.schedule(r.ctx)
+
+	for i := 0; i < r.o.Workers; i++ {
+		r.wg.Add(1)
+		go r.work(r.ctx)
+	}
+}
+
+// Stop scheduling refreshes and wait for the ones in progress to finish. Safe to call more than once.
+func (r *refresher[T]) stop() {
+	r.stopOnce.Do(func() {
+		r.cancel()
+		r.wg.Wait()
+	})
+}
+
+// Record a read of the key along with the action to refresh it with.
+func (r *refresher[T]) track(key string, action ActionFunc[T]) {
+	// Keys are no longer refreshed once stopped.
+	if r.ctx.Err() != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	tk, ok := r.keys[key]
+	if !ok {
+		if len(r.keys) >= r.o.MaxKeys {
+			return
+		}
+
+		tk = &trackedKey[T]{
+			key:         key,
+			windowStart: now,
+		}
+		r.keys[key] = tk
+	}
+
+	if now.Sub(tk.windowStart) > r.o.Window {
+		tk.hits = 0
+		tk.windowStart = now
+	}
+
+	// Keep the latest action, as older ones might hold on to stale state.
+	tk.action = action
+	tk.hits++
+	tk.lastRead = now
+}
+
+func (r *refresher[T]) schedule(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.o.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.enqueue(ctx)
+		}
+	}
+}
+
+func (r *refresher[T]) enqueue(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for key, tk := range r.keys {
+		// Stop tracking keys which are no longer read.
+		if now.Sub(tk.lastRead) > r.o.Window {
+			delete(r.keys, key)
+			continue
+		}
+
+		if tk.queued || tk.hits < r.o.MinHits || now.Before(tk.checkAfter) {
+			continue
+		}
+
+		select {
+		case r.jobs <- tk:
+			tk.queued = true
+		case <-ctx.Done():
+			return
+		default:
+			// Workers are busy, try again on the next tick.
+			return
+		}
+	}
+}
+
+func (r *refresher[T]) work(ctx context.Context) {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tk := <-r.jobs:
+			r.mu.Lock()
+			action := tk.action
+			r.mu.Unlock()
+
+			checkAfter, err := r.refresh(ctx, tk.key, action)
+			if err != nil {
+				r.logError(tk.key, err)
+			}
+
+			r.mu.Lock()
+			if err != nil {
+				tk.failures++
+				checkAfter = time.Now().Add(r.getBackoff(tk.failures))
+			} else {
+				tk.failures = 0
+			}
+			tk.checkAfter = checkAfter
+			tk.queued = false
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *refresher[T]) logError(key string, err error) {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		r.a.o.Logger.Error("refresh action panicked", key, panicErr, string(panicErr.Stack))
+		return
+	}
+
+	r.a.o.Logger.Error(err)
+}
+
+// Refresh the key if it is about to expire. Returns the time in which the key should be checked again.
+func (r *refresher[T]) refresh(ctx context.Context, key string, action ActionFunc[T]) (checkAfter time.Time, err error) {
+	// Panics would otherwise crash the process, and are counted as failures instead.
+	defer func() {
+		if rec := recover(); rec != nil {
+			checkAfter, err = time.Time{}, &PanicError{Value: rec, Stack: debug.Stack()}
+		}
+	}()
+
+	e, err := r.a.getEntry(ctx, key)
+	if err != nil {
+		// Missing values are left to be loaded by the next read.
+		if errors.Is(err, adapter.ErrNotFound) {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	// Values which never expire need no refresh.
+	if e.ExpiresAt == 0 {
+		return time.Time{}, nil
+	}
+
+	refreshAt := time.Unix(0, e.ExpiresAt).Add(-r.getLead(e))
+	if time.Now().Before(refreshAt) {
+		return refreshAt, nil
+	}
+
+	done, ok := r.a.beginRevalidation(key)
+	if !ok {
+		return time.Time{}, nil
+	}
+	defer done()
+
+	return time.Time{}, r.a.recompute(ctx, key, e.ExpiresAt, action)
+}
+
+// The lead defaults to a tenth of the TTL of the entry, which might differ from the actor TTL.
+func (r *refresher[T]) getLead(e entry[T]) time.Duration {
+	if r.o.Lead > 0 {
+		return r.o.Lead
+	}
+
+	if e.TTL > 0 {
+		return time.Duration(e.TTL) / 10
+	}
+
+	return r.a.ttl / 10
+}
+
+// Delay before retrying a key which failed to refresh, doubling on each failure up to the window.
+func (r *refresher[T]) getBackoff(failures int) time.Duration {
+	backoff := r.o.Interval
+	for i := 1; i < failures && backoff < r.o.Window; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, r.o.Window)
+}
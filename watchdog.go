@@ -0,0 +1,76 @@
+package wracha
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ezraisw/wracha/adapter"
+	"github.com/ezraisw/wracha/logger"
+)
+
+// Keeps extending the lease of the lock until it is released.
+type watchedLock struct {
+	adapter.ExtendableLock
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// Start a watchdog for the lock if its lease can be extended.
+func watchLock(lock adapter.Lock, lockKey string, logger logger.Logger) adapter.Lock {
+	el, ok := lock.(adapter.ExtendableLock)
+	if !ok || el.TTL() <= 0 {
+		return lock
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wl := &watchedLock{
+		ExtendableLock: el,
+		stop:           cancel,
+		done:           make(chan struct{}),
+	}
+	go wl.watch(ctx, lockKey, logger)
+
+	return wl
+}
+
+func (l *watchedLock) watch(ctx context.Context, lockKey string, logger logger.Logger) {
+	defer close(l.done)
+
+	// Extend well before the lease runs out, leaving room for a few failed attempts.
+	ticker := time.NewTicker(l.TTL() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.Extend(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Error("error while extending lock", lockKey, err)
+
+			// The lock has been lost, there is nothing left to extend.
+			if errors.Is(err, adapter.ErrFailedExtend) {
+				return
+			}
+			continue
+		}
+
+		logger.Debug("lock extended", lockKey)
+	}
+}
+
+func (l *watchedLock) Release(ctx context.Context) error {
+	l.stop()
+	<-l.done
+
+	return l.ExtendableLock.Release(ctx)
+}